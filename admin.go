@@ -0,0 +1,323 @@
+package elseql
+
+import (
+	"strings"
+)
+
+/*
+ * Index administration statements:
+ *
+ *   CREATE INDEX index [(name KEYWORD, ts DATE(format='yyyy-MM-dd'), body TEXT)] [WITH (shards=1, replicas=0)]
+ *   DROP INDEX index
+ *   ALTER INDEX index ADD name TYPE [, name TYPE...]
+ *
+ * Field types are ElasticSearch field types, with optional mapping parameters.
+ * WITH sets the index settings (shards and replicas are short for number_of_shards and number_of_replicas).
+ * DROP INDEX only accepts index patterns and lists of indices with WithDropPatterns.
+ */
+
+var (
+	adminColumns = []string{"index", "acknowledged"}
+
+	settingNames = map[string]string{
+		"shards":   "number_of_shards",
+		"replicas": "number_of_replicas",
+	}
+)
+
+/*
+ * Allow DROP INDEX with index patterns (logs-*) and comma separated lists of indices
+ */
+func WithDropPatterns() QueryOption {
+	return func(o *queryOptions) {
+		o.dropPattern = true
+	}
+}
+
+/*
+ * A field in CREATE INDEX or ALTER INDEX
+ */
+type FieldDefinition struct {
+	Name    string
+	Type    string      // ElasticSearch field type (lowercase)
+	Options []NameValue // mapping parameters (i.e. format, analyzer)
+}
+
+func (p *ElseParser) parseCreate() (err error) {
+	switch p.query.Object = p.parseKeywords([]Keyword{INDEX, ALIAS}, NO_KEYWORD); p.query.Object {
+	case INDEX:
+		if p.query.Index, err = p.parseIndexName(); err != nil {
+			return
+		}
+
+		if match, _ := p.parseToken('(', true); match {
+			if p.query.FieldList, err = p.parseFieldDefinitions(); err != nil {
+				return
+			}
+			if err = p.parseParen(CLOSEP); err != nil {
+				return
+			}
+		}
+
+		if match, _ := p.parseKeyword(WITH, true); match {
+			p.query.Settings, err = p.parseOptions()
+		}
+
+	case ALIAS:
+		err = p.parseCreateAlias()
+
+	default:
+		err = p.parseError(INDEX.String(), ALIAS.String())
+	}
+
+	return
+}
+
+func (p *ElseParser) parseDrop() (err error) {
+	switch p.query.Object = p.parseKeywords([]Keyword{INDEX, ALIAS}, NO_KEYWORD); p.query.Object {
+	case INDEX:
+		p.query.Index, err = p.parseIndexName()
+
+	case ALIAS:
+		err = p.parseDropAlias()
+
+	default:
+		err = p.parseError(INDEX.String(), ALIAS.String())
+	}
+
+	return
+}
+
+func (p *ElseParser) parseAlter() (err error) {
+	switch p.query.Object = p.parseKeywords([]Keyword{INDEX}, NO_KEYWORD); p.query.Object {
+	case INDEX:
+		if p.query.Index, err = p.parseIndexName(); err != nil {
+			return
+		}
+		if err = p.parseRequired(ADD); err != nil {
+			return
+		}
+
+		p.query.FieldList, err = p.parseFieldDefinitions()
+
+	default:
+		err = p.parseError(INDEX.String())
+	}
+
+	return
+}
+
+/*
+ * Parse a (comma separated) list of field definitions: name TYPE [(options)]
+ */
+func (p *ElseParser) parseFieldDefinitions() ([]FieldDefinition, error) {
+	var fields []FieldDefinition
+
+	for {
+		name, err := p.parseIdentifier()
+		if err != nil {
+			return nil, err
+		}
+
+		ftype := p.parseId(false)
+		if ftype == "" {
+			return nil, p.parseError("field type")
+		}
+
+		field := FieldDefinition{Name: name, Type: strings.ToLower(ftype)}
+
+		if p.nextToken() == '(' {
+			if field.Options, err = p.parseOptions(); err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, field)
+
+		if match, _ := p.parseToken(list_sep, true); !match {
+			return fields, nil
+		}
+	}
+}
+
+func formatFieldDefinitions(fields []FieldDefinition) string {
+	list := make([]string, 0, len(fields))
+	for _, f := range fields {
+		s := f.Name + " " + strings.ToUpper(f.Type)
+		if len(f.Options) > 0 {
+			s += formatOptions(f.Options)
+		}
+
+		list = append(list, s)
+	}
+
+	return strings.Join(list, ", ")
+}
+
+func formatAdmin(q *Query) string {
+	s := q.Command.String() + " " + q.Object.String() + " " + formatIndexName(q.Index)
+
+	switch q.Command {
+	case CREATE:
+		if len(q.FieldList) > 0 {
+			s += " (" + formatFieldDefinitions(q.FieldList) + ")"
+		}
+		if len(q.Settings) > 0 {
+			s += " " + WITH.String() + " " + formatOptions(q.Settings)
+		}
+
+	case ALTER:
+		s += " " + ADD.String() + " " + formatFieldDefinitions(q.FieldList)
+	}
+
+	return s
+}
+
+/*
+ * Return the mapping properties for a list of fields (a.b is added as property b of object a)
+ */
+func fieldProperties(fields []FieldDefinition) jmap {
+	properties := jmap{}
+
+	for _, f := range fields {
+		mapping := jmap{"type": f.Type}
+		for _, o := range f.Options {
+			mapping[o.Name] = o.Value
+		}
+
+		parts := strings.Split(f.Name, string(id_sep))
+		props := properties
+
+		for _, part := range parts[:len(parts)-1] {
+			parent, ok := props[part].(jmap)
+			if !ok {
+				parent = jmap{}
+				props[part] = parent
+			}
+
+			sub, ok := parent["properties"].(jmap)
+			if !ok {
+				sub = jmap{}
+				parent["properties"] = sub
+			}
+
+			props = sub
+		}
+
+		if current, ok := props[parts[len(parts)-1]].(jmap); ok && current["properties"] != nil {
+			mapping["properties"] = current["properties"] // object defined after its fields
+		}
+
+		props[parts[len(parts)-1]] = mapping
+	}
+
+	return properties
+}
+
+/*
+ * Return the index settings for the WITH options
+ */
+func indexSettings(options []NameValue) jmap {
+	settings := jmap{}
+
+	for _, o := range options {
+		if name, ok := settingNames[strings.ToLower(o.Name)]; ok {
+			settings[name] = o.Value
+		} else {
+			settings[o.Name] = o.Value
+		}
+	}
+
+	return settings
+}
+
+/*
+ * Create an index with the specified fields and settings (both can be empty)
+ */
+func (es *ElseSearch) CreateIndex(index string, fields []FieldDefinition, settings map[string]interface{}) (map[string]interface{}, error) {
+	body := jmap{}
+	if len(fields) > 0 {
+		body["mappings"] = jmap{"properties": fieldProperties(fields)}
+	}
+	if len(settings) > 0 {
+		body["settings"] = settings
+	}
+
+	res, err := es.request("PUT", index, nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	es.ClearMappings()
+	return fullResult(res), nil
+}
+
+/*
+ * Delete an index
+ */
+func (es *ElseSearch) DropIndex(index string) (map[string]interface{}, error) {
+	res, err := es.request("DELETE", index, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	es.ClearMappings()
+	return fullResult(res), nil
+}
+
+/*
+ * Add fields to the mapping of an index
+ */
+func (es *ElseSearch) AddFields(index string, fields []FieldDefinition) (map[string]interface{}, error) {
+	res, err := es.request("PUT", index+"/_mapping", nil, jmap{"properties": fieldProperties(fields)})
+	if err != nil {
+		return nil, err
+	}
+
+	es.ClearMappings()
+	return fullResult(res), nil
+}
+
+/*
+ * Return the result of an administration statement: the response for Full, or a single row
+ */
+func rowResult(res, doc jmap, columns []string, nilValue string, returnType ReturnType) (jmap, error) {
+	if returnType == Full {
+		return res, nil
+	}
+
+	return searchResult(nil, []jmap{doc}, columns, 1, nil, nilValue, returnType)
+}
+
+/*
+ * Execute a CREATE, DROP or ALTER statement
+ */
+func (es *ElseSearch) admin(query *Query, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	if err := checkIndex(query.Index, queryString); err != nil {
+		return nil, err
+	}
+	if query.Command == DROP && !opts.dropPattern && strings.ContainsAny(query.Index, "*,") {
+		return nil, SearchError{
+			Err:   ParseError{Msg: "DROP INDEX with an index pattern or a list of indices requires WithDropPatterns"},
+			Query: queryString,
+		}
+	}
+
+	var res jmap
+	var err error
+
+	switch query.Command {
+	case CREATE:
+		res, err = es.CreateIndex(query.Index, query.FieldList, indexSettings(query.Settings))
+	case DROP:
+		res, err = es.DropIndex(query.Index)
+	case ALTER:
+		res, err = es.AddFields(query.Index, query.FieldList)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rowResult(res, jmap{"index": query.Index, "acknowledged": res["acknowledged"]}, adminColumns, nilValue, returnType)
+}
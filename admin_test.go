@@ -0,0 +1,96 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAdmin(t *testing.T) {
+	tests := []struct {
+		statement   string
+		format      string
+		destructive bool
+	}{
+		{
+			"CREATE INDEX idx (name KEYWORD, ts DATE, body TEXT) WITH (shards=1, replicas=0)",
+			"CREATE INDEX idx (name KEYWORD, ts DATE, body TEXT) WITH (shards=1, replicas=0)",
+			false,
+		},
+		{
+			"create index 'logs-2024' (ts date(format='yyyy-MM-dd'), user.name keyword)",
+			`CREATE INDEX "logs-2024" (ts DATE(format="yyyy-MM-dd"), user.name KEYWORD)`,
+			false,
+		},
+		{"CREATE INDEX idx", "CREATE INDEX idx", false},
+		{"DROP INDEX idx", "DROP INDEX idx", true},
+		{"ALTER INDEX idx ADD n LONG, tags KEYWORD", "ALTER INDEX idx ADD n LONG, tags KEYWORD", false},
+		{"CREATE INDEX idx (index KEYWORD, add LONG, with TEXT)", "CREATE INDEX idx (index KEYWORD, add LONG, with TEXT)", false},
+		{"ALTER INDEX idx ADD index KEYWORD", "ALTER INDEX idx ADD index KEYWORD", false},
+		{"DELETE FROM idx", "DELETE FROM idx", true},
+		{"UPDATE idx SET a = 1", "UPDATE idx\nSET a = 1", true},
+		{"UPDATE idx SET a = 1 LIMIT 10", "UPDATE idx\nSET a = 1\nLIMIT 10", true},
+		{"UPDATE idx SET a = 1 WHERE b = 2", "UPDATE idx\nSET a = 1\nWHERE b = 2", false},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		q := parser.Query()
+		if f := Format(q); f != test.format {
+			t.Errorf("%v: expected %q, got %q", test.statement, test.format, f)
+		}
+		if q.IsDestructive() != test.destructive {
+			t.Errorf("%v: expected destructive %v", test.statement, test.destructive)
+		}
+	}
+
+	for _, statement := range []string{
+		"CREATE TABLE idx",
+		"CREATE INDEX idx (name)",
+		"DROP INDEX",
+		"ALTER INDEX idx n LONG",
+		"ALTER INDEX idx ADD n LONG WITH (shards=1)",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+
+	es := NewClient("http://localhost:9200")
+	for _, statement := range []string{"DROP INDEX 'logs-*'", "DROP INDEX 'a,b'"} {
+		if _, err := es.Search(statement, "", "", "", Data); err == nil {
+			t.Errorf("%v: expected error without WithDropPatterns", statement)
+		}
+		if _, err := es.Search(statement, "", "", "", Data, WithDropPatterns()); err != nil {
+			t.Errorf("%v: %v", statement, err)
+		}
+	}
+}
+
+func TestFieldProperties(t *testing.T) {
+	properties := fieldProperties([]FieldDefinition{
+		{Name: "name", Type: "keyword"},
+		{Name: "user.id", Type: "long"},
+		{Name: "user.ts", Type: "date", Options: []NameValue{{"format", "epoch_millis"}}},
+	})
+
+	expected := jmap{
+		"name": jmap{"type": "keyword"},
+		"user": jmap{"properties": jmap{
+			"id": jmap{"type": "long"},
+			"ts": jmap{"type": "date", "format": "epoch_millis"},
+		}},
+	}
+
+	if !reflect.DeepEqual(properties, expected) {
+		t.Errorf("unexpected properties %v", properties)
+	}
+
+	settings := indexSettings([]NameValue{{"shards", 1}, {"Replicas", 0}, {"refresh_interval", "1s"}})
+	if !reflect.DeepEqual(settings, jmap{"number_of_shards": 1, "number_of_replicas": 0, "refresh_interval": "1s"}) {
+		t.Errorf("unexpected settings %v", settings)
+	}
+}
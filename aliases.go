@@ -0,0 +1,252 @@
+package elseql
+
+import (
+	"strings"
+)
+
+/*
+ * Alias statements:
+ *
+ *   CREATE ALIAS name FOR index [WHERE expr]   (filtered alias)
+ *   SWAP ALIAS name FROM old_index TO new_index (atomic remove and add)
+ *   DROP ALIAS name [FROM index]
+ *   ROLLOVER name [WITH (max_age='7d', max_docs=1000000)]
+ */
+
+var (
+	aliasColumns    = []string{"alias", "acknowledged"}
+	rolloverColumns = []string{"old_index", "new_index", "rolled_over"}
+)
+
+func (p *ElseParser) parseCreateAlias() (err error) {
+	if p.query.Name, err = p.parseIndexName(); err != nil {
+		return
+	}
+	if err = p.parseRequired(FOR); err != nil {
+		return
+	}
+	if p.query.Index, err = p.parseIndexName(); err != nil {
+		return
+	}
+
+	return p.parseWhere()
+}
+
+func (p *ElseParser) parseDropAlias() (err error) {
+	if p.query.Name, err = p.parseIndexName(); err != nil {
+		return
+	}
+
+	if match, _ := p.parseKeyword(FROM, true); match {
+		p.query.Index, err = p.parseIndexName()
+	}
+
+	return
+}
+
+func (p *ElseParser) parseSwap() (err error) {
+	if err = p.parseRequired(ALIAS); err != nil {
+		return
+	}
+
+	p.query.Object = ALIAS
+
+	if p.query.Name, err = p.parseIndexName(); err != nil {
+		return
+	}
+	if err = p.parseRequired(FROM); err != nil {
+		return
+	}
+	if p.query.Index, err = p.parseIndexName(); err != nil {
+		return
+	}
+	if err = p.parseRequired(TO); err != nil {
+		return
+	}
+
+	p.query.Target, err = p.parseIndexName()
+	return
+}
+
+func (p *ElseParser) parseRollover() (err error) {
+	p.query.Object = ALIAS
+
+	if p.query.Name, err = p.parseIndexName(); err != nil {
+		return
+	}
+
+	if match, _ := p.parseKeyword(WITH, true); match {
+		p.query.Settings, err = p.parseOptions()
+	}
+
+	return
+}
+
+func formatAlias(q *Query) string {
+	switch q.Command {
+	case CREATE:
+		s := CREATE.String() + " " + ALIAS.String() + " " + formatIndexName(q.Name) + " " + FOR.String() + " " + formatIndexName(q.Index)
+		if q.WhereExpr != nil {
+			s += "\n" + WHERE.String() + " " + formatTopExpression(q.WhereExpr)
+		}
+		return s
+
+	case DROP:
+		s := DROP.String() + " " + ALIAS.String() + " " + formatIndexName(q.Name)
+		if q.Index != "" {
+			s += " " + FROM.String() + " " + formatIndexName(q.Index)
+		}
+		return s
+
+	case SWAP:
+		return SWAP.String() + " " + ALIAS.String() + " " + formatIndexName(q.Name) +
+			" " + FROM.String() + " " + formatIndexName(q.Index) + " " + TO.String() + " " + formatIndexName(q.Target)
+
+	case ROLLOVER:
+		s := ROLLOVER.String() + " " + formatIndexName(q.Name)
+		if len(q.Settings) > 0 {
+			s += " " + WITH.String() + " " + formatOptions(q.Settings)
+		}
+		return s
+	}
+
+	return q.Command.String()
+}
+
+/*
+ * Return the body of an _aliases request that adds an alias (with an optional filter) to an index
+ */
+func addAliasActions(alias, index string, filter map[string]interface{}) jmap {
+	add := jmap{"index": index, "alias": alias}
+	if filter != nil {
+		add["filter"] = filter
+	}
+
+	return jmap{"actions": jarr{jmap{"add": add}}}
+}
+
+/*
+ * Return the body of an _aliases request that moves an alias from one index to another
+ */
+func swapAliasActions(alias, from, to string) jmap {
+	return jmap{"actions": jarr{
+		jmap{"remove": jmap{"index": from, "alias": alias}},
+		jmap{"add": jmap{"index": to, "alias": alias}},
+	}}
+}
+
+/*
+ * Add an alias to an index. filter is an optional query for a filtered alias.
+ */
+func (es *ElseSearch) CreateAlias(alias, index string, filter map[string]interface{}) (map[string]interface{}, error) {
+	res, err := es.request("POST", "_aliases", nil, addAliasActions(alias, index, filter))
+	if err != nil {
+		return nil, err
+	}
+
+	return fullResult(res), nil
+}
+
+/*
+ * Move an alias from one index to another, in a single atomic request
+ */
+func (es *ElseSearch) SwapAlias(alias, from, to string) (map[string]interface{}, error) {
+	res, err := es.request("POST", "_aliases", nil, swapAliasActions(alias, from, to))
+	if err != nil {
+		return nil, err
+	}
+
+	return fullResult(res), nil
+}
+
+/*
+ * Remove an alias from an index (or from all the indices if index is empty)
+ */
+func (es *ElseSearch) DropAlias(alias, index string) (map[string]interface{}, error) {
+	if index == "" {
+		index = "_all"
+	}
+
+	res, err := es.request("DELETE", index+"/_alias/"+alias, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return fullResult(res), nil
+}
+
+/*
+ * Roll over an alias to a new index. conditions (i.e. max_age, max_docs, max_size) can be empty.
+ */
+func (es *ElseSearch) Rollover(alias string, conditions map[string]interface{}) (map[string]interface{}, error) {
+	var body interface{}
+	if len(conditions) > 0 {
+		body = jmap{"conditions": conditions}
+	}
+
+	res, err := es.request("POST", alias+"/_rollover", nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	es.ClearMappings()
+	return fullResult(res), nil
+}
+
+/*
+ * Execute an alias statement
+ */
+func (es *ElseSearch) alias(query *Query, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	for _, name := range []string{query.Name, query.Index, query.Target} {
+		if err := checkIndex(name, queryString); err != nil {
+			return nil, err
+		}
+	}
+
+	var res jmap
+	var err error
+
+	switch query.Command {
+	case CREATE:
+		var filter jmap
+
+		if query.WhereExpr != nil {
+			source := *query
+			source.Command = SELECT
+			source.Size = -1
+
+			jq, _, _, err := translateQuery(&source, queryString, "", opts)
+			if err != nil {
+				return nil, err
+			}
+
+			filter, _ = jq["query"].(jmap)
+		}
+
+		res, err = es.CreateAlias(query.Name, query.Index, filter)
+
+	case DROP:
+		res, err = es.DropAlias(query.Name, query.Index)
+
+	case SWAP:
+		res, err = es.SwapAlias(query.Name, query.Index, query.Target)
+
+	case ROLLOVER:
+		conditions := jmap{}
+		for _, c := range query.Settings {
+			conditions[strings.ToLower(c.Name)] = c.Value
+		}
+
+		if res, err = es.Rollover(query.Name, conditions); err != nil {
+			return nil, err
+		}
+
+		return rowResult(res, res, rolloverColumns, nilValue, returnType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rowResult(res, jmap{"alias": query.Name, "acknowledged": res["acknowledged"]}, aliasColumns, nilValue, returnType)
+}
@@ -0,0 +1,68 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseAliases(t *testing.T) {
+	tests := []struct {
+		statement string
+		format    string
+	}{
+		{"CREATE ALIAS current FOR 'logs-2024'", `CREATE ALIAS current FOR "logs-2024"`},
+		{"create alias errors for logs where level = 'error'", "CREATE ALIAS errors FOR logs\nWHERE level = \"error\""},
+		{"SWAP ALIAS current FROM 'logs-2024' TO 'logs-2025'", `SWAP ALIAS current FROM "logs-2024" TO "logs-2025"`},
+		{"DROP ALIAS current", "DROP ALIAS current"},
+		{"DROP ALIAS current FROM logs", "DROP ALIAS current FROM logs"},
+		{"ROLLOVER current", "ROLLOVER current"},
+		{"ROLLOVER current WITH (max_age='7d', max_docs=1000)", `ROLLOVER current WITH (max_age="7d", max_docs=1000)`},
+		{"CREATE ALIAS current FOR logs WHERE to = 'x' AND alias = 1", "CREATE ALIAS current FOR logs\nWHERE to = \"x\"\n  AND alias = 1"},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		q := parser.Query()
+		if q.Object != ALIAS {
+			t.Errorf("%v: unexpected query %v", test.statement, q)
+		}
+		if f := Format(q); f != test.format {
+			t.Errorf("%v: expected %q, got %q", test.statement, test.format, f)
+		}
+	}
+
+	for _, statement := range []string{
+		"CREATE ALIAS current logs",
+		"SWAP ALIAS current FROM a",
+		"SWAP current FROM a TO b",
+		"ROLLOVER",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+}
+
+func TestAliasActions(t *testing.T) {
+	expected := jmap{"actions": jarr{
+		jmap{"remove": jmap{"index": "old", "alias": "a"}},
+		jmap{"add": jmap{"index": "new", "alias": "a"}},
+	}}
+
+	if actions := swapAliasActions("a", "old", "new"); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("unexpected actions %v", actions)
+	}
+
+	filter := jmap{"term": jmap{"level": "error"}}
+	expected = jmap{"actions": jarr{
+		jmap{"add": jmap{"index": "logs", "alias": "errors", "filter": filter}},
+	}}
+
+	if actions := addAliasActions("errors", "logs", filter); !reflect.DeepEqual(actions, expected) {
+		t.Errorf("unexpected actions %v", actions)
+	}
+}
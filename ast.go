@@ -0,0 +1,226 @@
+package elseql
+
+/*
+ * Typed AST for WHERE and FILTER expressions.
+ *
+ * Expression.AST converts a parsed expression to a tree of nodes that can be inspected with Walk
+ * and modified with Rewrite; NewExpression converts the tree back to an Expression that can be
+ * assigned to a Query.
+ */
+
+type Node interface {
+	node()
+}
+
+/*
+ * field op value, where op is one of EQ, NE, LT, LTE, GT, GTE, IN (with a []interface{} value)
+ * or RANGE_EXPR (with a Range value)
+ */
+type Predicate struct {
+	Field string
+	Op    Operator
+	Value interface{}
+}
+
+/*
+ * AND/OR of operands (Op is OP_AND or OP_OR)
+ */
+type Boolean struct {
+	Op       Operator
+	Operands []Node
+}
+
+/*
+ * NOT operand
+ */
+type Not struct {
+	Operand Node
+}
+
+/*
+ * A query in Lucene syntax
+ */
+type StringQuery struct {
+	Query string
+}
+
+/*
+ * EXIST field (or MISSING field)
+ */
+type Exists struct {
+	Field   string
+	Missing bool
+}
+
+func (*Predicate) node()   {}
+func (*Boolean) node()     {}
+func (*Not) node()         {}
+func (*StringQuery) node() {}
+func (*Exists) node()      {}
+
+func (e *Expression) Op() Operator {
+	return e.op
+}
+
+func (e *Expression) Operands() []interface{} {
+	return e.operands
+}
+
+/*
+ * Return the expression as a tree of nodes (nil for a nil expression)
+ */
+func (e *Expression) AST() Node {
+	if e == nil {
+		return nil
+	}
+
+	switch e.op {
+	case STRING_EXPR:
+		return &StringQuery{Query: e.operands[0].(string)}
+
+	case EXISTS_EXPR:
+		return &Exists{Field: e.operands[0].(string)}
+
+	case MISSING_EXPR:
+		return &Exists{Field: e.operands[0].(string), Missing: true}
+
+	case OP_NOT:
+		return &Not{Operand: e.operands[0].(*Expression).AST()}
+
+	case OP_AND, OP_OR:
+		b := &Boolean{Op: e.op}
+		for _, op := range e.operands {
+			b.Operands = append(b.Operands, op.(*Expression).AST())
+		}
+		return b
+	}
+
+	nv := e.operands[0].(NameValue)
+	return &Predicate{Field: nv.Name, Op: e.op, Value: nv.Value}
+}
+
+/*
+ * Convert a tree of nodes to an Expression (nil for a nil node or an empty Boolean)
+ */
+func NewExpression(n Node) *Expression {
+	switch n := n.(type) {
+	case *Predicate:
+		return nameValueExpression(n.Op, n.Field, n.Value)
+
+	case *Boolean:
+		e := newExpression(n.Op)
+		for _, op := range n.Operands {
+			if expr := NewExpression(op); expr != nil {
+				e.addOperand(expr)
+			}
+		}
+
+		switch len(e.operands) {
+		case 0:
+			return nil
+		case 1:
+			return e.operands[0].(*Expression)
+		}
+		return e
+
+	case *Not:
+		if expr := NewExpression(n.Operand); expr != nil {
+			return singleOperand(OP_NOT, expr)
+		}
+
+	case *StringQuery:
+		return singleOperand(STRING_EXPR, n.Query)
+
+	case *Exists:
+		if n.Missing {
+			return singleOperand(MISSING_EXPR, n.Field)
+		}
+		return singleOperand(EXISTS_EXPR, n.Field)
+	}
+
+	return nil
+}
+
+/*
+ * Visit the tree in depth-first order. If fn returns false the children of the node are not visited.
+ */
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	case *Boolean:
+		for _, op := range n.Operands {
+			Walk(op, fn)
+		}
+
+	case *Not:
+		Walk(n.Operand, fn)
+	}
+}
+
+/*
+ * Rewrite the tree bottom-up: fn is called for each node (after its children have been rewritten)
+ * and returns the replacement node. Returning nil removes the node from its parent.
+ */
+func Rewrite(n Node, fn func(Node) Node) Node {
+	switch nn := n.(type) {
+	case nil:
+		return nil
+
+	case *Boolean:
+		operands := make([]Node, 0, len(nn.Operands))
+		for _, op := range nn.Operands {
+			if op = Rewrite(op, fn); op != nil {
+				operands = append(operands, op)
+			}
+		}
+
+		if len(operands) == 0 {
+			return nil
+		}
+
+		nn.Operands = operands
+
+	case *Not:
+		if nn.Operand = Rewrite(nn.Operand, fn); nn.Operand == nil {
+			return nil
+		}
+	}
+
+	return fn(n)
+}
+
+/*
+ * Rewrite the expression (see Rewrite) and return the new expression
+ */
+func (e *Expression) Rewrite(fn func(Node) Node) *Expression {
+	return NewExpression(Rewrite(e.AST(), fn))
+}
+
+/*
+ * Return the list of fields referenced in the expression, in order of appearance
+ */
+func (e *Expression) Fields() (fields []string) {
+	seen := map[string]bool{}
+
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			fields = append(fields, f)
+		}
+	}
+
+	Walk(e.AST(), func(n Node) bool {
+		switch n := n.(type) {
+		case *Predicate:
+			add(n.Field)
+		case *Exists:
+			add(n.Field)
+		}
+		return true
+	})
+
+	return
+}
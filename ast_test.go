@@ -0,0 +1,47 @@
+package elseql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestASTRoundTrip(t *testing.T) {
+	parser := NewParser("SELECT * FROM t WHERE NOT (a = 1 OR b IN (2, 3)) AND EXIST c AND 'd:x*' AND e.f >= 4")
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	where := parser.Query().WhereExpr
+	if expr := NewExpression(where.AST()); !reflect.DeepEqual(expr, where) {
+		t.Errorf("round trip failed: %v %v", where, expr)
+	}
+
+	if fields := strings.Join(where.Fields(), ","); fields != "a,b,c,e.f" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
+
+func TestASTRewrite(t *testing.T) {
+	parser := NewParser("SELECT * FROM t WHERE a = 1 AND secret = 'x' AND (b = 2 OR secret != 'y')")
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	where := parser.Query().WhereExpr.Rewrite(func(n Node) Node {
+		switch p := n.(type) {
+		case *Predicate:
+			if p.Field == "secret" {
+				return nil
+			}
+			if p.Field == "a" {
+				p.Field = "alias_a"
+			}
+		}
+		return n
+	})
+
+	if qs := where.QueryString(); qs != "alias_a:1 AND b:2" {
+		t.Errorf("unexpected rewritten query %v", qs)
+	}
+}
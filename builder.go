@@ -0,0 +1,258 @@
+package elseql
+
+import (
+	"strings"
+)
+
+/*
+ * Fluent query builder.
+ *
+ * Builds the same Query returned by the parser, without going through ElseSQL text:
+ *
+ *     q := elseql.Select("a", "b").From("idx").
+ *         Where(elseql.Eq("x", 1).And(elseql.Gt("y", 2))).
+ *         OrderBy("a DESC").
+ *         Limit(10)
+ *
+ *     jq, index, columns, err := q.DSL()
+ *
+ * or execute it with ElseSearch.SearchQuery(q.Query(), ...)
+ */
+
+type Builder struct {
+	query Query
+}
+
+/*
+ * Start a new query selecting the specified fields (no fields or "*" selects all fields)
+ */
+func Select(fields ...string) *Builder {
+	b := &Builder{}
+	b.query.Command = SELECT
+	b.query.Retrieve = NO_KEYWORD
+	b.query.Size = -1
+
+	if len(fields) == 1 && fields[0] == string(all_fields) {
+		fields = nil
+	}
+
+	b.query.SelectList = fields
+	return b
+}
+
+/*
+ * Start a new SELECT DISTINCT query
+ */
+func SelectDistinct(fields ...string) *Builder {
+	b := Select(fields...)
+	b.query.Distinct = true
+	return b
+}
+
+func (b *Builder) Facets(fields ...string) *Builder {
+	b.query.FacetList = append(b.query.FacetList, fields...)
+	return b
+}
+
+func (b *Builder) From(index string) *Builder {
+	b.query.Index = index
+	return b
+}
+
+/*
+ * Set the WHERE expression (calling Where multiple times ANDs the expressions)
+ */
+func (b *Builder) Where(e *Expression) *Builder {
+	b.query.WhereExpr = b.query.WhereExpr.And(e)
+	return b
+}
+
+/*
+ * Set the FILTER expression (calling Filter multiple times ANDs the expressions)
+ */
+func (b *Builder) Filter(e *Expression) *Builder {
+	b.query.FilterExpr = b.query.FilterExpr.And(e)
+	return b
+}
+
+func (b *Builder) Highlight(fields ...string) *Builder {
+	b.query.HighlightList = append(b.query.HighlightList, fields...)
+	return b
+}
+
+/*
+ * Add sort fields, as "field" or "field ASC" / "field DESC"
+ */
+func (b *Builder) OrderBy(fields ...string) *Builder {
+	for _, f := range fields {
+		parts := strings.Fields(f)
+		if len(parts) == 0 {
+			continue
+		}
+
+		order := ASC.Lower()
+		if len(parts) > 1 && strings.EqualFold(parts[1], DESC.String()) {
+			order = DESC.Lower()
+		}
+
+		b.query.OrderList = append(b.query.OrderList, NameValue{parts[0], order})
+	}
+
+	return b
+}
+
+func (b *Builder) Limit(size int) *Builder {
+	b.query.Size = size
+	return b
+}
+
+func (b *Builder) Offset(from int) *Builder {
+	b.query.From = from
+	return b
+}
+
+func (b *Builder) After(after string) *Builder {
+	b.query.After = after
+	return b
+}
+
+/*
+ * Return the query built so far
+ */
+func (b *Builder) Query() *Query {
+	q := b.query
+	return &q
+}
+
+/*
+ * Return the ElseSQL text for the query
+ */
+func (b *Builder) String() string {
+	return Format(&b.query)
+}
+
+/*
+ * Return the ElasticSearch query object, the index and the list of columns to return (as ParseQuery)
+ */
+func (b *Builder) DSL(options ...QueryOption) (jq jmap, index string, columns []string, err error) {
+	return b.Query().SearchRequest(b.query.After, options...)
+}
+
+//
+// Expression constructors
+//
+
+/*
+ * Convert a Go value to one of the types returned by the parser (values that cannot be converted,
+ * like parameters, are used as they are)
+ */
+func builderValue(v interface{}) interface{} {
+	if bv, err := bindValue(v); err == nil {
+		return bv
+	}
+
+	return v
+}
+
+func Eq(field string, value interface{}) *Expression {
+	return nameValueExpression(EQ, field, builderValue(value))
+}
+
+func Ne(field string, value interface{}) *Expression {
+	return nameValueExpression(NE, field, builderValue(value))
+}
+
+func Lt(field string, value interface{}) *Expression {
+	return nameValueExpression(LT, field, builderValue(value))
+}
+
+func Lte(field string, value interface{}) *Expression {
+	return nameValueExpression(LTE, field, builderValue(value))
+}
+
+func Gt(field string, value interface{}) *Expression {
+	return nameValueExpression(GT, field, builderValue(value))
+}
+
+func Gte(field string, value interface{}) *Expression {
+	return nameValueExpression(GTE, field, builderValue(value))
+}
+
+/*
+ * field IN (values...)
+ */
+func In(field string, values ...interface{}) *Expression {
+	list := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		list = append(list, builderValue(v))
+	}
+
+	return nameValueExpression(IN, field, list)
+}
+
+/*
+ * EXIST field
+ */
+func Exist(field string) *Expression {
+	return singleOperand(EXISTS_EXPR, field)
+}
+
+/*
+ * MISSING field
+ */
+func Missing(field string) *Expression {
+	return singleOperand(MISSING_EXPR, field)
+}
+
+/*
+ * A query in Lucene syntax (as a string expression in ElseSQL)
+ */
+func Lucene(query string) *Expression {
+	return singleOperand(STRING_EXPR, query)
+}
+
+/*
+ * Return a new expression, e AND other (a nil expression is ignored)
+ */
+func (e *Expression) And(other *Expression) *Expression {
+	return combineExpressions(OP_AND, e, other)
+}
+
+/*
+ * Return a new expression, e OR other (a nil expression is ignored)
+ */
+func (e *Expression) Or(other *Expression) *Expression {
+	return combineExpressions(OP_OR, e, other)
+}
+
+/*
+ * Return a new expression, NOT e
+ */
+func (e *Expression) Not() *Expression {
+	if e == nil {
+		return nil
+	}
+
+	return singleOperand(OP_NOT, e)
+}
+
+func combineExpressions(op Operator, left, right *Expression) *Expression {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+
+	e := newExpression(op)
+
+	for _, expr := range []*Expression{left, right} {
+		if expr.op == op { // flatten a AND b AND c
+			e.operands = append(e.operands, expr.operands...)
+		} else {
+			e.addOperand(expr)
+		}
+	}
+
+	return e
+}
@@ -0,0 +1,82 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		builder *Builder
+		query   string
+	}{
+		{
+			Select("a", "b").From("idx").Where(Eq("x", 1).And(Gt("y", 2))).OrderBy("a DESC").Limit(10),
+			"SELECT a, b FROM idx WHERE x = 1 AND y > 2 ORDER BY a DESC LIMIT 10",
+		},
+		{
+			Select().From("idx").Where(In("n", 1, 2, 3).Or(Eq("s", "x")).Or(Exist("z").Not())).Offset(5).Limit(10),
+			"SELECT * FROM idx WHERE n IN (1, 2, 3) OR s = 'x' OR NOT EXIST z LIMIT 5, 10",
+		},
+		{
+			Select("*").Facets("f").From("idx").Where(Lucene("a:b")).Where(Lte("d", 1.5)).Filter(Missing("m")).OrderBy("a"),
+			"SELECT * FACETS f FROM idx WHERE 'a:b' AND d <= 1.5 FILTER MISSING m ORDER BY a",
+		},
+		{
+			SelectDistinct("a").From("idx").Where(Eq("x", true).And(Ne("y", "z").Or(Lt("n", 0)))),
+			"SELECT DISTINCT a FROM idx WHERE x = true AND (y != 'z' OR n < 0)",
+		},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.query)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("parse %q: %v", test.query, err)
+		}
+
+		expected := parser.Query()
+		if q := test.builder.Query(); !reflect.DeepEqual(q, expected) {
+			t.Errorf("builder for %q\ngot:      %v\nexpected: %v", test.query, q, expected)
+		}
+
+		jq, index, columns, err := test.builder.DSL()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		pq, pindex, pcolumns, err := ParseQuery(test.query, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(jq, pq) || index != pindex || !reflect.DeepEqual(columns, pcolumns) {
+			t.Errorf("DSL for %q\ngot:      %v %v %v\nexpected: %v %v %v", test.query, jq, index, columns, pq, pindex, pcolumns)
+		}
+	}
+}
+
+func TestBuilderParams(t *testing.T) {
+	b := Select("a").From("idx").Where(Eq("x", Param{Index: 1}).And(In("n", Param{Name: "list"})))
+
+	jq, _, _, err := b.DSL(WithArgs(5), WithParams(map[string]interface{}{"list": []int{1, 2}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pq, _, _, err := ParseQuery("SELECT a FROM idx WHERE x = 5 AND n IN (1, 2)", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(jq, pq) {
+		t.Errorf("unexpected DSL %v, expected %v", jq, pq)
+	}
+
+	if nv := b.Query().WhereExpr.operands[0].(*Expression).operands[0].(NameValue); nv.Value != (Param{Index: 1}) {
+		t.Errorf("query was modified: %v", nv)
+	}
+
+	if _, _, _, err := b.DSL(WithArgs(5)); err == nil {
+		t.Error("expected error for missing parameter")
+	}
+}
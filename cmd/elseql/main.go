@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -22,6 +23,35 @@ import (
 var (
 	keywords = []string{
 		"SELECT",
+		"DISTINCT",
+		"EXPLAIN",
+		"ANALYZE",
+		"SHOW INDICES",
+		"SHOW ALIASES",
+		"SHOW STATS",
+		"DESCRIBE",
+		"LIKE",
+		"INSERT INTO",
+		"INTO",
+		"VALUES",
+		"UPDATE",
+		"SET",
+		"DELETE FROM",
+		"CREATE INDEX",
+		"DROP INDEX",
+		"ALTER INDEX",
+		"ADD",
+		"WITH",
+		"CREATE ALIAS",
+		"DROP ALIAS",
+		"SWAP ALIAS",
+		"ROLLOVER",
+		"FOR",
+		"TO",
+		"DURING LAST",
+		"SINCE",
+		"UNTIL",
+		"now",
 		// "COUNT",
 		"FACETS",
 		"FROM",
@@ -36,10 +66,12 @@ var (
 		"NEXT",
 		"NOT",
 		"EXIST",
+		"RAW",
 		"_all",
 		".keyword",
 
 		".format",
+		".fromjson",
 		".output",
 	}
 
@@ -93,6 +125,121 @@ func toStrings(li []interface{}) []string {
 	return ls
 }
 
+// print an error and, for parse errors, the query line with a caret under the error position
+func printError(q string, err error) {
+	log.Println("ERROR", err)
+
+	var perr elseql.ParseError
+	if !errors.As(err, &perr) || perr.Pos.Line < 1 {
+		return
+	}
+
+	lines := strings.Split(q, "\n")
+	if perr.Pos.Line > len(lines) {
+		return
+	}
+
+	line := []rune(lines[perr.Pos.Line-1])
+	caret := make([]rune, 0, perr.Pos.Column)
+	for i := 0; i < perr.Pos.Column-1 && i < len(line); i++ {
+		if line[i] == '\t' {
+			caret = append(caret, '\t')
+		} else {
+			caret = append(caret, ' ')
+		}
+	}
+
+	fmt.Fprintln(os.Stderr, "  "+string(line))
+	fmt.Fprintln(os.Stderr, "  "+string(caret)+"^")
+}
+
+// print validation errors and warnings, return false if there are errors
+func printDiagnostics(q string, diagnostics []elseql.Diagnostic) bool {
+	ok := true
+
+	for _, d := range diagnostics {
+		if d.Severity == elseql.SeverityError {
+			printError(q, elseql.ParseError{Pos: d.Pos, Msg: d.Msg})
+			ok = false
+		} else {
+			log.Println("WARNING", d)
+		}
+	}
+
+	return ok
+}
+
+// split the input in statements (a JSON query is a single statement)
+func splitStatements(q string) []string {
+	if strings.HasPrefix(q, "{") {
+		return []string{q}
+	}
+
+	var statements []string
+	for _, st := range elseql.SplitScript(q) {
+		statements = append(statements, st.Text)
+	}
+
+	return statements
+}
+
+// return true if the statement drops an index or deletes documents
+func destructive(q string) bool {
+	parser := elseql.NewParser(q)
+	return parser.Parse() == nil && parser.Query().IsDestructive()
+}
+
+// ask for confirmation before executing a destructive statement
+func confirm(line *liner.State) bool {
+	answer, err := line.Prompt("this statement will drop or delete data, continue? [y/N] ")
+	return err == nil && strings.ToLower(strings.TrimSpace(answer)) == "y"
+}
+
+// elseql fmt [query]: print the query (or the query read from stdin) in canonical form
+func formatQuery(args []string) int {
+	q := strings.Join(args, " ")
+
+	if q == "" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Println("ERROR", err)
+			return 1
+		}
+
+		q = string(b)
+	}
+
+	f, err := elseql.FormatString(q)
+	if err != nil {
+		printError(q, err)
+		return 1
+	}
+
+	fmt.Println(f)
+	return 0
+}
+
+// .fromjson [index] {json}: print the ElseSQL statement for an ElasticSearch search request
+func fromJSON(arg string) {
+	index := ""
+	if i := strings.Index(arg, "{"); i > 0 {
+		index = strings.TrimSpace(arg[:i])
+		arg = arg[i:]
+	}
+
+	q, unsupported, err := elseql.FromJSON(index, arg)
+	if err != nil {
+		log.Println("ERROR", err)
+		return
+	}
+
+	fmt.Println(q)
+
+	for _, u := range unsupported {
+		log.Println("UNSUPPORTED", u)
+	}
+}
+
 func main() {
 	url := flag.String("url", "http://localhost:9200", "ElasticSearch endpoint")
 	insecure := flag.Bool("insecure", false, "if true, allow possibly insecure HTTPS connetions")
@@ -100,9 +247,20 @@ func main() {
 	pprint := flag.String("print", " ", `how to print/indent output: use pretty for pretty-print or "  " to indent`)
 	proxy := flag.Bool("proxy", false, "if true, we are talking to a proxy server")
 	proxyQ := flag.Bool("proxy-query", false, "if true, we are talking to a proxy server, but parsing the query locally")
+	validate := flag.Bool("validate", false, "if true, validate queries against the index mapping before searching")
+	file := flag.String("file", "", "execute the statements in the file (separated by ;) and exit")
+	keyword := flag.Bool("keyword", false, "if true, use the keyword sub-field of text fields for comparisons, sorting and facets")
+	refresh := flag.String("refresh", "", "refresh parameter for INSERT (true, false or wait_for), UPDATE and DELETE statements")
+	timestamp := flag.String("timestamp", "", "timestamp field for DURING, SINCE and UNTIL (default @timestamp)")
+	dryRun := flag.Bool("dry-run", false, "if true, UPDATE, DELETE and SELECT INTO statements only return the number of matching documents")
+	dropPatterns := flag.Bool("drop-patterns", false, "if true, DROP INDEX accepts index patterns and lists of indices")
 	flag.BoolVar(&elseql.Debug, "debug", false, "log debug info")
 	flag.Parse()
 
+	if flag.Arg(0) == "fmt" {
+		os.Exit(formatQuery(flag.Args()[1:]))
+	}
+
 	q := strings.Join(flag.Args(), " ")
 	rType := returnType(*format, elseql.Data)
 	rFormat := *format
@@ -121,7 +279,7 @@ func main() {
 			if *proxyQ {
 				jq, index, _, err := elseql.ParseQuery(q, "")
 				if err != nil {
-					log.Println("ERROR", err.Error())
+					printError(q, err)
 					return -1, -1
 				}
 
@@ -151,10 +309,10 @@ func main() {
 				err = res.ResponseError()
 			}
 			if err != nil {
-                                log.Println("ERROR", err)
-                                if res != nil && res.ContentLength > 0 {
-                                    log.Println(" ", string(res.Content()))
-                                }
+				log.Println("ERROR", err)
+				if res != nil && res.ContentLength > 0 {
+					log.Println(" ", string(res.Content()))
+				}
 				return -1, -1
 			}
 
@@ -200,18 +358,51 @@ func main() {
 		es.AllowInsecure(*insecure)
 
 		runQuery = func(q string, out io.Writer) (int, int) {
-			res, err := es.Search(q, "", "", "", rType)
+			if *validate && !strings.HasPrefix(q, "{") {
+				diagnostics, err := es.Validate(q)
+				if err != nil {
+					printError(q, err)
+					return -1, -1
+				}
+				if !printDiagnostics(q, diagnostics) {
+					return -1, -1
+				}
+			}
+
+			var options []elseql.QueryOption
+			if *keyword {
+				options = append(options, elseql.WithKeywordFields(nil))
+			}
+			if *refresh != "" {
+				options = append(options, elseql.WithRefresh(*refresh))
+			}
+			if *dryRun {
+				options = append(options, elseql.WithDryRun())
+			}
+			if *dropPatterns {
+				options = append(options, elseql.WithDropPatterns())
+			}
+			if *timestamp != "" {
+				options = append(options, elseql.WithTimestampField(*timestamp))
+			}
+			options = append(options, elseql.WithProgress(func(status map[string]interface{}) {
+				log.Println("PROGRESS", status["total"], "total", status["created"], "created", status["updated"], "updated", status["deleted"], "deleted")
+			}))
+
+			res, err := es.Search(q, "", "", "", rType, options...)
 			if err != nil {
-				log.Println("ERROR", err)
+				printError(q, err)
 				return -1, -1
 			}
 
-			if rFormat == "csv" || rFormat == "csv-headers" {
+			rows, table := res["rows"].([]interface{}) // not returned by EXPLAIN, that is always printed as JSON
+
+			if table && (rFormat == "csv" || rFormat == "csv-headers") {
 				w := csv.NewWriter(out)
 				if rFormat == "csv-headers" {
 					w.Write(res["columns"].([]string))
 				}
-				for _, r := range res["rows"].([]interface{}) {
+				for _, r := range rows {
 					w.Write(toStrings(r.([]interface{})))
 				}
 				w.Flush()
@@ -224,17 +415,28 @@ func main() {
 				enc.Encode(res)
 			}
 
-			if rFormat == "full" {
-				hits := res["hits"].(map[string]interface{})
+			if hits, ok := res["hits"].(map[string]interface{}); ok && rFormat == "full" {
 				return len(hits["hits"].([]interface{})), int(hits["total"].(float64))
 			}
 
-			return len(res["rows"].([]interface{})), res["total"].(int)
+			total, _ := res["total"].(int)
+			return len(rows), total
 		}
 	}
 
+	if *file != "" {
+		b, err := os.ReadFile(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		q = string(b)
+	}
+
 	if q != "" {
-		runQuery(q, os.Stdout)
+		for _, st := range splitStatements(q) {
+			runQuery(st, os.Stdout)
+		}
 		return
 	}
 
@@ -312,7 +514,7 @@ func main() {
 			if l == "]]]" {
 				multi = false
 			} else {
-				cmd += " " + l
+				cmd += "\n" + l
 				continue
 			}
 		}
@@ -321,11 +523,11 @@ func main() {
 		if len(l) == 0 {
 			continue
 		}
-                if strings.HasPrefix(cmd, "#") {
-                        continue
-                }
+		if strings.HasPrefix(cmd, "#") {
+			continue
+		}
 
-		line.AppendHistory(cmd)
+		line.AppendHistory(strings.Replace(cmd, "\n", " ", -1))
 		hasHistory = true
 
 		if strings.HasPrefix(cmd, ".format ") {
@@ -335,6 +537,11 @@ func main() {
 			continue
 		}
 
+		if strings.HasPrefix(cmd, ".fromjson ") {
+			fromJSON(strings.TrimSpace(strings.TrimPrefix(cmd, ".fromjson")))
+			continue
+		}
+
 		if cmd == ".output" {
 			if os.Stdout != stdout {
 				os.Stdout.Close()
@@ -359,11 +566,17 @@ func main() {
 			continue
 		}
 
-		fmt.Println()
+		for _, st := range splitStatements(cmd) {
+			if destructive(st) && !confirm(line) {
+				continue
+			}
 
-		n, t := runQuery(cmd, os.Stdout)
-		if n >= 0 {
-			fmt.Printf("\n%v ROWS, %v TOTAL\n", n, t)
+			fmt.Println()
+
+			n, t := runQuery(st, os.Stdout)
+			if n >= 0 {
+				fmt.Printf("\n%v ROWS, %v TOTAL\n", n, t)
+			}
 		}
 	}
 }
@@ -0,0 +1,149 @@
+package elseql
+
+/*
+ * Statements other than SELECT (commands).
+ *
+ * A command is parsed in the same Query structure, with Command set to the first keyword of the statement,
+ * and executed by ElseSearch.Search (ParseQuery only translates SELECT statements).
+ */
+
+// keywords that start a command
+var commandKeywords = []Keyword{SHOW, DESCRIBE, INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, SWAP, ROLLOVER}
+
+/*
+ * Return true if the statement is a command (queries created without the parser may have no Command)
+ */
+func (q *Query) isCommand() bool {
+	return q.Command != SELECT && q.Command != 0
+}
+
+/*
+ * Return true if the statement drops an index or an alias, deletes documents or updates all the documents of an index
+ */
+func (q *Query) IsDestructive() bool {
+	switch q.Command {
+	case DROP, DELETE:
+		return true
+
+	case UPDATE:
+		return q.WhereExpr == nil && q.TimeRange == nil
+	}
+
+	return false
+}
+
+/*
+ * Return the clauses of a command (the command keyword has already been parsed)
+ */
+func (p *ElseParser) commandClauses() []func() error {
+	switch p.query.Command {
+	case SHOW:
+		return []func() error{p.parseShow, p.parseEnd}
+
+	case DESCRIBE:
+		return []func() error{p.parseDescribe, p.parseEnd}
+
+	case INSERT:
+		return []func() error{p.parseInsert, p.parseInsertSource}
+
+	case UPDATE:
+		return []func() error{p.parseUpdate, p.parseWhere, p.parseTimeRange, p.parseMaxDocs, p.parseEnd, p.bindParams}
+
+	case DELETE:
+		return []func() error{p.parseDelete, p.parseWhere, p.parseTimeRange, p.parseMaxDocs, p.parseEnd, p.bindParams}
+
+	case CREATE:
+		return []func() error{p.parseCreate, p.parseEnd, p.bindParams}
+
+	case DROP:
+		return []func() error{p.parseDrop, p.parseEnd}
+
+	case ALTER:
+		return []func() error{p.parseAlter, p.parseEnd}
+
+	case SWAP:
+		return []func() error{p.parseSwap, p.parseEnd}
+
+	case ROLLOVER:
+		return []func() error{p.parseRollover, p.parseEnd}
+	}
+
+	return nil
+}
+
+/*
+ * Return the canonical text for a command
+ */
+func formatCommand(q *Query) string {
+	switch q.Command {
+	case SHOW:
+		return formatShow(q)
+
+	case DESCRIBE:
+		return DESCRIBE.String() + " " + formatIndexName(q.Index)
+
+	case INSERT:
+		return formatInsert(q)
+
+	case UPDATE, DELETE:
+		return formatByQuery(q)
+
+	case CREATE, DROP, ALTER, SWAP, ROLLOVER:
+		if q.Object == ALIAS {
+			return formatAlias(q)
+		}
+
+		return formatAdmin(q)
+	}
+
+	return q.Command.String()
+}
+
+/*
+ * Return the full result of a command (responses that are not objects are returned as "response")
+ */
+func fullResult(res jobj) jmap {
+	if m, ok := res.(jmap); ok {
+		return m
+	}
+
+	return jmap{"response": res}
+}
+
+/*
+ * Execute a command
+ */
+func (es *ElseSearch) command(query *Query, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	switch query.Command {
+	case SHOW:
+		switch query.Show {
+		case INDICES:
+			return es.ShowIndices(query.Pattern, nilValue, returnType)
+		case ALIASES:
+			return es.ShowAliases(nilValue, returnType)
+		case STATS:
+			return es.ShowStats(query.Index, nilValue, returnType)
+		}
+
+	case DESCRIBE:
+		return es.Describe(query.Index, nilValue, returnType)
+
+	case INSERT:
+		return es.insert(query, queryString, nilValue, returnType, opts)
+
+	case UPDATE, DELETE:
+		return es.byQuery(query, queryString, nilValue, returnType, opts)
+
+	case CREATE, DROP, ALTER, SWAP, ROLLOVER:
+		if query.Object == ALIAS {
+			return es.alias(query, queryString, nilValue, returnType, opts)
+		}
+
+		return es.admin(query, queryString, nilValue, returnType, opts)
+	}
+
+	return nil, SearchError{
+		Err:   ParseError{Msg: "unsupported statement " + query.Command.String()},
+		Query: queryString,
+	}
+}
@@ -0,0 +1,130 @@
+package elseql
+
+import (
+	"fmt"
+	"sort"
+	"text/scanner"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+/*
+ * An error or warning reported by ParseDiagnostics
+ */
+type Diagnostic struct {
+	Pos      scanner.Position // Pos.Line is 0 if not available
+	Severity Severity
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v:%v: %v: %v", d.Pos.Line, d.Pos.Column, d.Severity, d.Msg)
+}
+
+// keywords that start a clause, where the parser can resume after an error (contextual ones only if followed by an argument)
+var clauseKeywords = map[Keyword]bool{
+	INTO:      true,
+	FACETS:    true,
+	SCRIPT:    true,
+	FROM:      true,
+	JOIN:      true,
+	WHERE:     true,
+	DURING:    true,
+	SINCE:     true,
+	UNTIL:     true,
+	FILTER:    true,
+	HIGHLIGHT: true,
+	ORDER:     true,
+	LIMIT:     true,
+	AFTER:     true,
+}
+
+/*
+ * Return true if the current token is a clause keyword where the parser can resume after an error.
+ * Contextual keywords are only clause keywords if they are followed by an argument (since = 1 is a comparison).
+ */
+func (p *ElseParser) atClause() bool {
+	k, ok := FindKeyword(p.lastText)
+	if !ok || !clauseKeywords[k] {
+		return false
+	}
+	if !contextKeywords[k] {
+		return true
+	}
+
+	switch tok, text := p.peekToken(); tok {
+	case scanner.Ident:
+		return !isReserved(text)
+
+	case scanner.String, scanner.RawString, scanner.Char, scanner.Int, scanner.Float, '?', '$', ':':
+		return true
+	}
+
+	return false
+}
+
+func (p *ElseParser) addDiagnostic(severity Severity, err error) {
+	d := Diagnostic{Severity: severity, Msg: err.Error()}
+
+	if perr, ok := err.(ParseError); ok {
+		d.Pos = perr.Pos
+		d.Msg = perr.message()
+	}
+
+	p.diagnostics = append(p.diagnostics, d)
+}
+
+func (p *ElseParser) warning(pos scanner.Position, msg string) {
+	p.addDiagnostic(SeverityWarning, ParseError{Pos: pos, Msg: msg})
+}
+
+/*
+ * Sort diagnostics by position (diagnostics without position go last)
+ */
+func sortDiagnostics(diagnostics []Diagnostic) {
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		pi, pj := diagnostics[i].Pos, diagnostics[j].Pos
+		if pi.Line == 0 || pj.Line == 0 {
+			return pi.Line != 0 && pj.Line == 0
+		}
+
+		return pi.Offset < pj.Offset
+	})
+}
+
+/*
+ * Return the first error in the diagnostics (or nil)
+ */
+func (p *ElseParser) firstError() error {
+	for _, d := range p.diagnostics {
+		if d.Severity == SeverityError {
+			return ParseError{Pos: d.Pos, Msg: d.Msg}
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Report warnings for statements that are valid but probably not what was intended
+ */
+func (p *ElseParser) lint() {
+	q := &p.query
+
+	if q.After != "" && len(q.OrderList) == 0 {
+		p.warning(p.afterPos, "AFTER without ORDER BY, results are sorted by _id")
+	}
+}
@@ -0,0 +1,138 @@
+package elseql
+
+/*
+ * SELECT DISTINCT support.
+ *
+ * A single field is deduplicated with field collapsing, multiple fields with a composite aggregation.
+ * In both cases the "last" value returned with the results can be used with AFTER to get the next page.
+ */
+
+// name of the composite aggregation used for SELECT DISTINCT a, b...
+const distinctAgg = "_distinct"
+
+func hasString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ * Update the search request for a SELECT DISTINCT query
+ */
+func distinctQuery(query *Query, jq jmap, after string) error {
+	var afterKey jobj
+
+	if after != "" {
+		if afterKey = decodeObject(after); afterKey == nil {
+			return ParseError{Msg: "invalid value for AFTER"}
+		}
+	}
+
+	order := map[string]string{}
+
+	for _, nv := range query.OrderList {
+		if !hasString(query.SelectList, nv.Name) {
+			return ParseError{Msg: "ORDER BY " + nv.Name + " is not in the DISTINCT list"}
+		}
+
+		order[nv.Name] = nv.Value.(string)
+	}
+
+	if len(query.SelectList) == 1 {
+		field := query.SelectList[0]
+		if order[field] == "" {
+			order[field] = "asc"
+		}
+
+		jq["collapse"] = jmap{"field": field}
+		jq["sort"] = []jmap{jmap{field: order[field]}}
+		if afterKey != nil {
+			jq["search_after"] = afterKey
+		}
+
+		return nil
+	}
+
+	if query.From > 0 {
+		return ParseError{Msg: "DISTINCT on multiple fields doesn't support LIMIT offset, use AFTER"}
+	}
+
+	// the ORDER BY fields sort first (the columns are still returned in the DISTINCT list order)
+	fields := make([]string, 0, len(query.SelectList))
+	for _, nv := range query.OrderList {
+		if !hasString(fields, nv.Name) {
+			fields = append(fields, nv.Name)
+		}
+	}
+	for _, field := range query.SelectList {
+		if !hasString(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+
+	sources := make(jarr, 0, len(fields))
+
+	for _, field := range fields {
+		terms := jmap{"field": field}
+		if o := order[field]; o != "" {
+			terms["order"] = o
+		}
+
+		sources = append(sources, jmap{field: jmap{"terms": terms}})
+	}
+
+	composite := jmap{"sources": sources}
+	if query.Size >= 0 {
+		composite["size"] = query.Size
+	}
+	if afterKey != nil {
+		composite["after"] = afterKey
+	}
+
+	aggs, _ := jq["aggs"].(jmap)
+	if aggs == nil {
+		aggs = jmap{}
+		jq["aggs"] = aggs
+	}
+
+	aggs[distinctAgg] = jmap{"composite": composite}
+
+	delete(jq, "sort")
+	delete(jq, "from")
+	delete(jq, "_source")
+	jq["size"] = 0
+	return nil
+}
+
+/*
+ * Build the result of a SELECT DISTINCT on multiple fields from the composite aggregation buckets.
+ * Total is the number of matching documents.
+ */
+func distinctResult(full jmap, columns []string, nilValue string, returnType ReturnType) (jmap, error) {
+	aggs, _ := full["aggregations"].(jmap)
+	distinct, _ := aggs[distinctAgg].(jmap)
+	buckets, _ := distinct["buckets"].(jarr)
+
+	docs := make([]jmap, 0, len(buckets))
+	for _, b := range buckets {
+		key, _ := b.(jmap)["key"].(jmap)
+		docs = append(docs, key)
+	}
+
+	var facets jobj
+	if len(aggs) > 1 {
+		f := jmap{}
+		for k, v := range aggs {
+			if k != distinctAgg {
+				f[k] = v
+			}
+		}
+		facets = f
+	}
+
+	return searchResult(facets, docs, columns, hitsTotal(full["hits"].(jmap)), distinct["after_key"], nilValue, returnType)
+}
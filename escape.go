@@ -0,0 +1,65 @@
+package elseql
+
+import (
+	"fmt"
+	"strings"
+)
+
+/*
+ * Values are always escaped when generating Lucene query strings, so that user input
+ * can't change the structure of the query. Raw Lucene fragments need an explicit opt-in:
+ *
+ *   - a string expression (WHERE 'field:foo* AND other:[1 TO 5]') is used as is
+ *   - a RAW value (WHERE field = RAW('foo*')) or a Raw parameter is used as is
+ */
+
+/*
+ * A raw Lucene query fragment, used without escaping in the generated query strings
+ */
+type Raw string
+
+// characters with a special meaning in Lucene query strings
+const luceneSpecial = `+-&|!(){}[]^"~*?:\/ `
+
+/*
+ * Escape all the Lucene special characters in a term
+ */
+func EscapeTerm(s string) string {
+	var b strings.Builder
+
+	for _, c := range s {
+		if strings.ContainsRune(luceneSpecial, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+/*
+ * Return a quoted (phrase) term. Only quotes and backslashes need to be escaped in a phrase.
+ */
+func QuoteTerm(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+/*
+ * Return a value formatted for a Lucene query string
+ */
+func queryValue(v interface{}) string {
+	switch vv := v.(type) {
+	case Raw:
+		return string(vv)
+
+	case string:
+		return QuoteTerm(vv)
+
+	case nil:
+		return "null"
+	}
+
+	return EscapeTerm(fmt.Sprintf("%v", v))
+}
@@ -0,0 +1,81 @@
+package elseql
+
+import "testing"
+
+func TestEscapeTerm(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"hello", "hello"},
+		{"a:b", `a\:b`},
+		{"a/b", `a\/b`},
+		{`a\b`, `a\\b`},
+		{"(a", `\(a`},
+		{"a]", `a\]`},
+		{"-1", `\-1`},
+		{"a b", `a\ b`},
+		{"foo*", `foo\*`},
+		{"què?", `què\?`},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeTerm(tt.in); got != tt.out {
+			t.Errorf("EscapeTerm(%q): expected %q, got %q", tt.in, tt.out, got)
+		}
+	}
+}
+
+func TestQueryStringEscaping(t *testing.T) {
+	tests := []struct {
+		query, out string
+	}{
+		{`x = "hello"`, `x:"hello"`},
+		{`x = "a:b"`, `x:"a:b"`},
+		{`x = "http://host/path"`, `x:"http://host/path"`},
+		{`x = "c:\\dir"`, `x:"c:\\dir"`},
+		{`x = "say \"hi\""`, `x:"say \"hi\""`},
+		{`x = "a AND b"`, `x:"a AND b"`},
+		{`x = "AND"`, `x:"AND"`},
+		{`x = "(a OR b"`, `x:"(a OR b"`},
+		{`x = "[1 TO 5"`, `x:"[1 TO 5"`},
+		{`x = "{a"`, `x:"{a"`},
+		{`x = "foo*"`, `x:"foo*"`},
+		{`x = 'it\'s'`, `x:"it's"`},
+		{`x = ""`, `x:*`},
+		{`x = 42`, `x:42`},
+		{`x = 1.5`, `x:1.5`},
+		{`x != "a:b"`, `NOT x:"a:b"`},
+		{`x < "a]b"`, `x:{* TO "a]b"}`},
+		{`x >= 10`, `x:[10 TO *]`},
+		{`x IN ("a", "b:c", 3)`, `x:("a" OR "b:c" OR 3)`},
+		{`x = RAW("foo*")`, `x:foo*`},
+		{`x = raw('[1 TO 5]')`, `x:[1 TO 5]`},
+		{`x > RAW("now-1d")`, `x:{now-1d TO *}`},
+		{`raw = "a*"`, `raw:"a*"`},
+		{`message.raw = "a"`, `message.raw:"a"`},
+		{`"x:foo* AND y:[1 TO 5]"`, `x:foo* AND y:[1 TO 5]`},
+	}
+
+	for _, tt := range tests {
+		parser := NewParser("SELECT * FROM t WHERE " + tt.query)
+		if err := parser.Parse(); err != nil {
+			t.Errorf("%v: %v", tt.query, err)
+			continue
+		}
+
+		if got := parser.Query().WhereExpr.QueryString(); got != tt.out {
+			t.Errorf("%v: expected %v, got %v", tt.query, tt.out, got)
+		}
+	}
+}
+
+func TestBoundValueEscaping(t *testing.T) {
+	parser := NewParser("SELECT * FROM t WHERE x = ? AND y = ?").Bind("a OR y:*", Raw("b*"))
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := parser.Query().WhereExpr.QueryString(); got != `x:"a OR y:*" AND y:b*` {
+		t.Errorf("unexpected query string %v", got)
+	}
+}
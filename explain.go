@@ -0,0 +1,224 @@
+package elseql
+
+import (
+	"math"
+)
+
+/*
+ * EXPLAIN and EXPLAIN ANALYZE.
+ *
+ * EXPLAIN SELECT ... returns the parsed expressions, the optimizer steps, the index path and the
+ * search request, without executing the query.
+ *
+ * EXPLAIN ANALYZE SELECT ... executes the query with "profile": true and returns the timings of each
+ * shard as a table (rows of shard, query_ms, rewrite_ms, collector_ms, aggregations_ms).
+ */
+
+var analyzeColumns = []string{"shard", "query_ms", "rewrite_ms", "collector_ms", "aggregations_ms"}
+
+/*
+ * Return an expression tree as JSON
+ */
+func astJSON(n Node) jobj {
+	switch n := n.(type) {
+	case *Predicate:
+		value := n.Value
+		if r, ok := value.(Range); ok {
+			v := jmap{}
+			if r.Lower != nil {
+				v[rangeKeys[r.lowerOperator()]] = r.Lower
+			}
+			if r.Upper != nil {
+				v[rangeKeys[r.upperOperator()]] = r.Upper
+			}
+			value = v
+		}
+
+		return jmap{"predicate": jmap{"field": n.Field, "op": n.Op.String(), "value": value}}
+
+	case *Boolean:
+		operands := make(jarr, 0, len(n.Operands))
+		for _, op := range n.Operands {
+			operands = append(operands, astJSON(op))
+		}
+
+		return jmap{n.Op.String(): operands}
+
+	case *Not:
+		return jmap{OP_NOT.String(): astJSON(n.Operand)}
+
+	case *StringQuery:
+		return jmap{"query_string": n.Query}
+
+	case *Exists:
+		if n.Missing {
+			return jmap{MISSING.String(): n.Field}
+		}
+
+		return jmap{EXIST.String(): n.Field}
+	}
+
+	return nil
+}
+
+/*
+ * Execute an EXPLAIN or EXPLAIN ANALYZE statement. jq, index and columns are the result of the translation.
+ */
+func (es *ElseSearch) explain(query *Query, jq jmap, index string, columns []string, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	if query.Analyze {
+		return es.analyze(query, jq, index, queryString, nilValue, returnType)
+	}
+
+	// the request was translated from the query with the bound parameters: describe the same query
+	query, err := query.bindOptions(opts)
+	if err != nil {
+		return nil, SearchError{
+			Err:   err,
+			Query: queryString,
+		}
+	}
+
+	statement := *query
+	statement.Explain = false
+
+	_, steps := prepareQuery(query, opts)
+
+	optimizer := make([]string, 0, len(steps))
+	for _, s := range steps {
+		optimizer = append(optimizer, s.String())
+	}
+
+	ast := jmap{}
+	if query.WhereExpr != nil {
+		ast["where"] = astJSON(query.WhereExpr.AST())
+	}
+	if query.FilterExpr != nil {
+		ast["filter"] = astJSON(query.FilterExpr.AST())
+	}
+
+	path := "_search"
+	if index != "" {
+		path = index + "/" + path
+	}
+
+	result := jmap{
+		"statement": Format(&statement),
+		"ast":       ast,
+		"optimizer": optimizer,
+		"index":     index,
+		"path":      path,
+		"request":   jq,
+		"columns":   columns,
+	}
+
+	if query.Join != nil {
+		result["join"] = query.Join.String()
+	}
+
+	return result, nil
+}
+
+/*
+ * Convert a time in nanoseconds to milliseconds (with 3 decimals)
+ */
+func nanosToMillis(ns float64) float64 {
+	return math.Round(ns/1e3) / 1e3
+}
+
+func nanos(v jobj) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case int:
+		return float64(n)
+	}
+
+	return 0
+}
+
+/*
+ * Return the timings of each shard from the profile section of a search response
+ */
+func profileRows(full jmap) []jmap {
+	profile, _ := full["profile"].(jmap)
+	shards, _ := profile["shards"].(jarr)
+
+	rows := make([]jmap, 0, len(shards))
+
+	for _, s := range shards {
+		shard, _ := s.(jmap)
+
+		var query, rewrite, collector, aggs float64
+
+		searches, _ := shard["searches"].(jarr)
+		for _, search := range searches {
+			sm, _ := search.(jmap)
+			rewrite += nanos(sm["rewrite_time"])
+
+			queries, _ := sm["query"].(jarr)
+			for _, q := range queries {
+				query += nanos(q.(jmap)["time_in_nanos"])
+			}
+
+			collectors, _ := sm["collector"].(jarr)
+			for _, c := range collectors {
+				collector += nanos(c.(jmap)["time_in_nanos"])
+			}
+		}
+
+		aggregations, _ := shard["aggregations"].(jarr)
+		for _, a := range aggregations {
+			aggs += nanos(a.(jmap)["time_in_nanos"])
+		}
+
+		rows = append(rows, jmap{
+			"shard":           shard["id"],
+			"query_ms":        nanosToMillis(query),
+			"rewrite_ms":      nanosToMillis(rewrite),
+			"collector_ms":    nanosToMillis(collector),
+			"aggregations_ms": nanosToMillis(aggs),
+		})
+	}
+
+	return rows
+}
+
+/*
+ * Execute the query with profiling and return the shard timings
+ */
+func (es *ElseSearch) analyze(query *Query, jq jmap, index, queryString, nilValue string, returnType ReturnType) (jmap, error) {
+	if query.Join != nil {
+		return nil, SearchError{
+			Err:   ParseError{Msg: "EXPLAIN ANALYZE is not supported with JOIN"},
+			Query: queryString,
+		}
+	}
+
+	if err := checkIndex(index, queryString); err != nil {
+		return nil, err
+	}
+
+	jq["profile"] = true
+
+	full, err := es.search(index, jq)
+	if err != nil {
+		return nil, err
+	}
+
+	if returnType == Full {
+		return full, nil
+	}
+
+	hits, _ := full["hits"].(jmap)
+
+	data, err := searchResult(nil, profileRows(full), analyzeColumns, hitsTotal(hits), nil, nilValue, returnType)
+	if err != nil {
+		return nil, SearchError{
+			Err:   err,
+			Query: queryString,
+		}
+	}
+
+	data["took"] = full["took"]
+	return data, nil
+}
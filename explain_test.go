@@ -0,0 +1,89 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gobs/simplejson"
+)
+
+func TestExplain(t *testing.T) {
+	es := NewClient("http://localhost:9200")
+
+	res, err := es.Search("EXPLAIN SELECT a FROM idx WHERE x > 1 AND x < 5 LIMIT 10", "", "", "", Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res["path"] != "idx/_search" || res["statement"] != "SELECT a\nFROM idx\nWHERE x > 1\n  AND x < 5\nLIMIT 10" {
+		t.Errorf("unexpected explain %v", res)
+	}
+
+	if steps := res["optimizer"].([]string); len(steps) != 1 || steps[0] != "merge ranges: x > 1 AND x < 5 -> (x > 1 AND x < 5)" {
+		t.Errorf("unexpected optimizer steps %q", steps)
+	}
+
+	where := res["ast"].(jmap)["where"]
+	expected := jmap{"AND": jarr{
+		jmap{"predicate": jmap{"field": "x", "op": ">", "value": 1}},
+		jmap{"predicate": jmap{"field": "x", "op": "<", "value": 5}},
+	}}
+
+	if !reflect.DeepEqual(where, expected) {
+		t.Errorf("expected %v, got %v", expected, where)
+	}
+
+	if _, ok := res["request"].(jmap)["query"].(jmap)["bool"]; !ok {
+		t.Errorf("unexpected request %v", res["request"])
+	}
+
+	// the optimizer steps and the AST are computed on the query with the bound parameters
+	unbound := NewParser("EXPLAIN SELECT a FROM idx WHERE x > ? AND x < ?").Query()
+	res, err = es.SearchQuery(unbound, "", "", Data, WithArgs(1, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if steps := res["optimizer"].([]string); len(steps) != 1 || steps[0] != "merge ranges: x > 1 AND x < 5 -> (x > 1 AND x < 5)" {
+		t.Errorf("unexpected optimizer steps %q", steps)
+	}
+	if where := res["ast"].(jmap)["where"]; !reflect.DeepEqual(where, expected) {
+		t.Errorf("expected %v, got %v", expected, where)
+	}
+
+	parser := NewParser("EXPLAIN ANALYZE SELECT * FROM idx")
+	if err := parser.Parse(); err != nil || !parser.Query().Analyze {
+		t.Fatal(err, parser.Query())
+	}
+	if f := Format(parser.Query()); f != "EXPLAIN ANALYZE\nSELECT *\nFROM idx" {
+		t.Errorf("unexpected format %q", f)
+	}
+}
+
+func TestProfileRows(t *testing.T) {
+	jj, err := simplejson.LoadString(`{"profile": {"shards": [{
+	  "id": "[n1][idx][0]",
+	  "searches": [{
+	    "query": [{"type": "TermQuery", "time_in_nanos": 1500000}],
+	    "rewrite_time": 2000,
+	    "collector": [{"name": "SimpleTopScoreDocCollector", "time_in_nanos": 250000}]
+	  }],
+	  "aggregations": [{"type": "StringTermsAggregator", "time_in_nanos": 3000000}]
+	}]}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := profileRows(jj.MustMap())
+	expected := []jmap{{
+		"shard":           "[n1][idx][0]",
+		"query_ms":        1.5,
+		"rewrite_ms":      0.002,
+		"collector_ms":    0.25,
+		"aggregations_ms": 3.0,
+	}}
+
+	if !reflect.DeepEqual(rows, expected) {
+		t.Errorf("expected %v, got %v", expected, rows)
+	}
+}
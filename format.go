@@ -0,0 +1,308 @@
+package elseql
+
+import (
+	"strconv"
+	"strings"
+)
+
+/*
+ * Canonical ElseSQL formatter.
+ *
+ * Format renders a Query as ElseSQL text with uppercase keywords, one clause per line and top level
+ * AND/OR operands on separate (indented) lines. Nested boolean expressions are always enclosed in parentheses,
+ * since the parser groups AND/OR from left to right, so that Parse(Format(q)) returns a query equal to q.
+ */
+
+const formatIndent = "  "
+
+/*
+ * Parse an ElseSQL statement and return it in canonical form
+ */
+func FormatString(queryString string) (string, error) {
+	parser := NewParser(queryString)
+	if err := parser.Parse(); err != nil {
+		return "", err
+	}
+
+	return Format(parser.Query()), nil
+}
+
+/*
+ * Return the canonical ElseSQL text for a query
+ */
+func Format(q *Query) string {
+	if q.isCommand() {
+		return formatCommand(q)
+	}
+
+	var lines []string
+
+	add := func(keyword Keyword, s string) {
+		lines = append(lines, keyword.String()+" "+s)
+	}
+
+	if q.Explain {
+		explain := EXPLAIN.String()
+		if q.Analyze {
+			explain += " " + ANALYZE.String()
+		}
+
+		lines = append(lines, explain)
+	}
+
+	sel := ""
+	if q.Distinct {
+		sel = DISTINCT.String() + " "
+	}
+	if retrieveKey(q.Retrieve) != "" {
+		sel += q.Retrieve.String() + " "
+	}
+
+	if len(q.SelectList) == 0 {
+		sel += string(all_fields)
+	} else {
+		fields := make([]string, 0, len(q.SelectList))
+		for _, f := range q.SelectList {
+			if opts, ok := q.FieldOptions[f]; ok {
+				f += formatOptions(opts)
+			}
+
+			fields = append(fields, f)
+		}
+
+		sel += strings.Join(fields, ", ")
+	}
+
+	add(SELECT, sel)
+
+	if q.Target != "" {
+		add(INTO, formatIndexName(q.Target))
+
+		if len(q.SetList) > 0 {
+			add(SET, formatAssignments(q.SetList))
+		}
+	}
+
+	if len(q.FacetList) > 0 {
+		add(FACETS, strings.Join(q.FacetList, ", "))
+	}
+
+	if q.Script != nil {
+		add(SCRIPT, q.Script.Name+" = "+formatValue(q.Script.Value))
+	}
+
+	from := formatIndexName(q.Index)
+	if q.Alias != "" && q.Alias != q.Index {
+		from += " " + q.Alias
+	}
+	add(FROM, from)
+
+	if j := q.Join; j != nil {
+		join := formatIndexName(j.Index)
+		if j.Alias != j.Index {
+			join += " " + j.Alias
+		}
+
+		add(JOIN, join+" "+ON.String()+" "+q.Alias+"."+j.Left+" = "+j.Alias+"."+j.Right)
+	}
+
+	if q.WhereExpr != nil {
+		add(WHERE, formatTopExpression(q.WhereExpr))
+	}
+
+	if q.TimeRange != nil {
+		lines = append(lines, formatTimeRange(q.TimeRange))
+	}
+
+	if q.FilterExpr != nil {
+		add(FILTER, formatTopExpression(q.FilterExpr))
+	}
+
+	if len(q.HighlightList) > 0 {
+		hl := strings.Join(q.HighlightList, ", ")
+		if len(q.HighlightOptions) > 0 {
+			hl += " " + formatOptions(q.HighlightOptions)
+		}
+
+		add(HIGHLIGHT, hl)
+	}
+
+	if len(q.OrderList) > 0 {
+		var order []string
+
+		for _, nv := range q.OrderList {
+			if nv.Name == "_script" {
+				order = append(order, strconv.Quote(stringify(nv.Value, "")))
+			} else if s, _ := nv.Value.(string); s != "" {
+				order = append(order, nv.Name+" "+strings.ToUpper(s))
+			} else {
+				order = append(order, nv.Name)
+			}
+		}
+
+		lines = append(lines, ORDER.String()+" "+BY.String()+" "+strings.Join(order, ", "))
+	}
+
+	if q.Size >= 0 {
+		if q.From > 0 {
+			add(LIMIT, strconv.Itoa(q.From)+", "+strconv.Itoa(q.Size))
+		} else {
+			add(LIMIT, strconv.Itoa(q.Size))
+		}
+	}
+
+	if q.After != "" {
+		add(AFTER, strconv.Quote(q.After))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/*
+ * Format a WHERE/FILTER expression, with top level AND/OR operands on separate lines
+ */
+func formatTopExpression(e *Expression) string {
+	if e.op != OP_AND && e.op != OP_OR {
+		return formatExpression(e)
+	}
+
+	operands := make([]string, 0, len(e.operands))
+	for _, op := range e.operands {
+		operands = append(operands, formatOperand(op.(*Expression)))
+	}
+
+	return strings.Join(operands, "\n"+formatIndent+e.op.String()+" ")
+}
+
+/*
+ * Format an expression
+ */
+func formatExpression(e *Expression) string {
+	switch e.op {
+	case STRING_EXPR:
+		return strconv.Quote(e.operands[0].(string))
+
+	case EXISTS_EXPR:
+		return EXIST.String() + " " + e.operands[0].(string)
+
+	case MISSING_EXPR:
+		return MISSING.String() + " " + e.operands[0].(string)
+
+	case OP_NOT:
+		if expr := e.operands[0].(*Expression); expr.op == OP_NOT {
+			return NOT.String() + " (" + formatExpression(expr) + ")"
+		} else {
+			return NOT.String() + " " + formatOperand(expr)
+		}
+
+	case OP_AND, OP_OR:
+		operands := make([]string, 0, len(e.operands))
+		for _, op := range e.operands {
+			operands = append(operands, formatOperand(op.(*Expression)))
+		}
+
+		return strings.Join(operands, " "+e.op.String()+" ")
+
+	case RANGE_EXPR:
+		nv := e.operands[0].(NameValue)
+		r := nv.Value.(Range)
+
+		var bounds []string
+		if r.Lower != nil {
+			bounds = append(bounds, nv.Name+" "+r.lowerOperator().String()+" "+formatValue(r.Lower))
+		}
+		if r.Upper != nil {
+			bounds = append(bounds, nv.Name+" "+r.upperOperator().String()+" "+formatValue(r.Upper))
+		}
+
+		return strings.Join(bounds, " "+OP_AND.String()+" ")
+
+	case IN:
+		nv := e.operands[0].(NameValue)
+		values, _ := nv.Value.([]interface{})
+
+		list := make([]string, 0, len(values))
+		for _, v := range values {
+			list = append(list, formatValue(v))
+		}
+
+		return nv.Name + " " + IN.String() + " (" + strings.Join(list, ", ") + ")"
+	}
+
+	nv := e.operands[0].(NameValue)
+	return nv.Name + " " + e.op.String() + " " + formatValue(nv.Value)
+}
+
+/*
+ * Format an operand of a boolean expression or NOT (nested boolean expressions and ranges are enclosed in parentheses)
+ */
+func formatOperand(e *Expression) string {
+	if e.op == OP_AND || e.op == OP_OR || e.op == RANGE_EXPR {
+		return "(" + formatExpression(e) + ")"
+	}
+
+	return formatExpression(e)
+}
+
+/*
+ * Format an index name (quoted if it's not a valid identifier)
+ */
+func formatIndexName(index string) string {
+	for i, c := range index {
+		if !(c == '_' || c == id_sep || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')) {
+			return strconv.Quote(index)
+		}
+	}
+
+	if _, ok := FindKeyword(index); ok || index == "" {
+		return strconv.Quote(index)
+	}
+
+	return index
+}
+
+/*
+ * Format a value as an ElseSQL literal
+ */
+func formatValue(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return strconv.Quote(vv)
+
+	case Raw:
+		return RAW.String() + "(" + strconv.Quote(string(vv)) + ")"
+
+	case Param:
+		return vv.String()
+
+	case DateMath:
+		return string(vv)
+
+	case int:
+		return strconv.Itoa(vv)
+
+	case float64:
+		s := strconv.FormatFloat(vv, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".eEIN") {
+			s += ".0" // keep it a float
+		}
+		return s
+
+	case bool:
+		return strconv.FormatBool(vv)
+	}
+
+	return strconv.Quote(stringify(v, ""))
+}
+
+/*
+ * Format (name=value, ...) options
+ */
+func formatOptions(options []NameValue) string {
+	list := make([]string, 0, len(options))
+	for _, o := range options {
+		list = append(list, o.Name+"="+formatValue(o.Value))
+	}
+
+	return "(" + strings.Join(list, ", ") + ")"
+}
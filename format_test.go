@@ -0,0 +1,48 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	queries := []string{
+		"SELECT * FROM table",
+		"SELECT a FROM 'logs-*' WHERE x = 1",
+		"select a, b.c facets d from table where x <= `hello` order by name asc, value desc limit 5, 10",
+		"SELECT DISTINCT a, b FROM table WHERE x = -1 AND y > 2.5 OR z IN ('a', 'b', 3) LIMIT 10 AFTER 'abc'",
+		"SELECT DOCVALUES ts(format=epoch_millis, x=1), b FROM table FILTER EXIST b",
+		"SELECT STORED * FROM table FILTER MISSING b",
+		"SELECT a SCRIPT s = 'doc[\"a\"].value * 2' FROM table WHERE 'a:foo* AND b:bar'",
+		"SELECT o.id, name FROM orders o JOIN customers c ON c.id = o.customer_id WHERE o.total > 10 ORDER BY total DESC",
+		"SELECT _id, a FROM table WHERE NOT (a = 1 OR b = 2) AND (c != 3 OR NOT d < 4) AND x = RAW('foo*') HIGHLIGHT a (pre='<b>', post='</b>')",
+		"SELECT a FROM table WHERE a = 1 AND b = 2 OR c = 3 AND d = true",
+		"SELECT a FROM table WHERE (a = 1 AND b = 2) AND NOT (NOT c = 3) AND e = ? AND f IN (:list) AND g = $2",
+		"SELECT a FROM table WHERE EXIST a AND b = 1e+06 AND c = 2.0 ORDER BY '{\"_script\": {\"type\": \"number\"}}'",
+	}
+
+	for _, q := range queries {
+		parser := NewParser(q)
+		if err := parser.Parse(); err != nil {
+			t.Errorf("%v: %v", q, err)
+			continue
+		}
+
+		formatted := Format(parser.Query())
+		t.Log("\n" + formatted)
+
+		reparsed := NewParser(formatted)
+		if err := reparsed.Parse(); err != nil {
+			t.Errorf("%v: %v", formatted, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(parser.Query(), reparsed.Query()) {
+			t.Errorf("round trip failed:\n%v\n%v", parser.Query(), reparsed.Query())
+		}
+
+		if f := Format(reparsed.Query()); f != formatted {
+			t.Errorf("format is not stable:\n%v\n%v", formatted, f)
+		}
+	}
+}
@@ -0,0 +1,589 @@
+package elseql
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gobs/simplejson"
+)
+
+/*
+ * Reverse translation, from an ElasticSearch search request to ElseSQL.
+ *
+ * The supported subset is: query (bool, term, terms, range, exists, query_string, match_phrase, match_all),
+ * post_filter, sort, from, size, _source, terms aggregations (as FACETS) and highlight fields.
+ * Constructs that cannot be represented are skipped and reported as a list of "path: reason" strings
+ * (i.e. the resulting statement may match more documents than the original request).
+ * Lossy conversions are also reported: term queries on strings become phrase matches (that also match analyzed text).
+ */
+
+/*
+ * Convert a search request (in JSON) for the specified index to an ElseSQL statement.
+ * Returns the statement and the list of unsupported constructs.
+ */
+func FromJSON(index, body string) (string, []string, error) {
+	jj, err := simplejson.LoadString(body)
+	if err != nil {
+		return "", nil, err
+	}
+
+	m, err := jj.Map()
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid search request: %v", err)
+	}
+
+	query, unsupported := FromDSL(index, m)
+	return Format(query), unsupported, nil
+}
+
+/*
+ * Convert a search request for the specified index to a Query.
+ * Returns the query and the list of unsupported constructs.
+ */
+func FromDSL(index string, body map[string]interface{}) (*Query, []string) {
+	c := dslConverter{}
+	q := &Query{Command: SELECT, Retrieve: NO_KEYWORD, Size: -1}
+
+	switch index {
+	case "":
+		q.Index = "_all"
+	default:
+		q.Index = strings.Replace(index, "/", ".", 1)
+	}
+
+	keys := make([]string, 0, len(body))
+	for k := range body {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := body[k]
+
+		switch k {
+		case "query":
+			q.WhereExpr = c.expression(k, v)
+
+		case "filter", "post_filter":
+			q.FilterExpr = q.FilterExpr.And(c.expression(k, v))
+
+		case "aggs", "aggregations":
+			q.FacetList = c.facets(k, v)
+
+		case "sort":
+			q.OrderList = c.sort(k, v)
+
+		case "from":
+			q.From = c.integer(k, v)
+
+		case "size":
+			q.Size = c.integer(k, v)
+
+		case "_source":
+			q.SelectList = c.source(k, v)
+
+		case "highlight":
+			q.HighlightList = c.highlight(k, v)
+
+		case "track_total_hits", "timeout": // no effect on the results
+			continue
+
+		default:
+			c.unsupported(k, "not supported")
+		}
+	}
+
+	if q.From > 0 && q.Size < 0 {
+		c.unsupported("from", "requires size")
+		q.From = 0
+	}
+
+	return q, c.errors
+}
+
+type dslConverter struct {
+	errors []string
+}
+
+func (c *dslConverter) unsupported(path, reason string) {
+	c.errors = append(c.errors, path+": "+reason)
+}
+
+/*
+ * Return true if a field name can be used in a statement (or report it)
+ */
+func (c *dslConverter) field(path, name string) bool {
+	if isIdentifier(name) {
+		return true
+	}
+
+	c.unsupported(path, "field name "+strconv.Quote(name)+" is not an identifier")
+	return false
+}
+
+/*
+ * Return the only key of an object like {"term": {...}}
+ */
+func singleKey(v interface{}) (string, interface{}, error) {
+	m, ok := v.(jmap)
+	if !ok || len(m) != 1 {
+		return "", nil, fmt.Errorf("expected an object with a single key")
+	}
+
+	for k, v := range m {
+		return k, v, nil
+	}
+
+	return "", nil, nil // not reached
+}
+
+/*
+ * Convert a JSON value to one of the types returned by the parser (numbers without decimals are integers)
+ */
+func dslValue(v interface{}) (interface{}, bool) {
+	switch vv := v.(type) {
+	case string, bool, int:
+		return vv, true
+
+	case float64:
+		if vv == math.Trunc(vv) && math.Abs(vv) < math.MaxInt32 {
+			return int(vv), true
+		}
+		return vv, true
+	}
+
+	return nil, false
+}
+
+func (c *dslConverter) integer(path string, v interface{}) int {
+	if n, ok := dslValue(v); ok {
+		if i, ok := n.(int); ok {
+			return i
+		}
+	}
+
+	c.unsupported(path, "expected an integer")
+	return 0
+}
+
+/*
+ * Convert a query clause to an expression (nil for match_all or unsupported clauses)
+ */
+func (c *dslConverter) expression(path string, v interface{}) *Expression {
+	name, body, err := singleKey(v)
+	if err != nil {
+		c.unsupported(path, err.Error())
+		return nil
+	}
+
+	path += "." + name
+
+	switch name {
+	case "match_all":
+		return nil
+
+	case "bool":
+		return c.boolExpression(path, body)
+
+	case "term", "match_phrase":
+		field, value, err := singleKey(body)
+		if err != nil {
+			c.unsupported(path, err.Error())
+			return nil
+		}
+		if !c.field(path, field) {
+			return nil
+		}
+
+		if m, ok := value.(jmap); ok { // {"field": {"value": v}} or {"field": {"query": v}}
+			if name == "term" {
+				value = m["value"]
+			} else {
+				value = m["query"]
+			}
+			if len(m) != 1 {
+				c.unsupported(path+"."+field, "options not supported")
+			}
+		}
+
+		if value, ok := dslValue(value); ok {
+			if _, ok := value.(string); ok && name == "term" {
+				c.unsupported(path+"."+field, "exact match converted to a phrase match")
+			}
+
+			return nameValueExpression(EQ, field, value)
+		}
+
+	case "terms":
+		field, value, err := singleKey(body)
+		if err != nil {
+			c.unsupported(path, err.Error())
+			return nil
+		}
+
+		if !c.field(path, field) {
+			return nil
+		}
+
+		list, ok := value.(jarr)
+		if !ok {
+			c.unsupported(path+"."+field, "expected a list of values")
+			return nil
+		}
+
+		values := make([]interface{}, 0, len(list))
+		phrase := false
+		for _, item := range list {
+			v, ok := dslValue(item)
+			if !ok {
+				c.unsupported(path+"."+field, "invalid value")
+				return nil
+			}
+
+			if _, ok := v.(string); ok {
+				phrase = true
+			}
+
+			values = append(values, v)
+		}
+
+		if phrase {
+			c.unsupported(path+"."+field, "exact match converted to a phrase match")
+		}
+
+		return nameValueExpression(IN, field, values)
+
+	case "range":
+		return c.rangeExpression(path, body)
+
+	case "exists":
+		if m, ok := body.(jmap); ok {
+			if field, ok := m["field"].(string); ok {
+				if !c.field(path, field) {
+					return nil
+				}
+
+				return singleOperand(EXISTS_EXPR, field)
+			}
+		}
+
+	case "query_string":
+		if m, ok := body.(jmap); ok {
+			if q, ok := m["query"].(string); ok {
+				for k := range m {
+					if k != "query" {
+						c.unsupported(path+"."+k, "option not supported")
+					}
+				}
+
+				return singleOperand(STRING_EXPR, q)
+			}
+		}
+
+	default:
+		c.unsupported(path, "query not supported")
+		return nil
+	}
+
+	c.unsupported(path, "invalid query")
+	return nil
+}
+
+var rangeOperators = []struct {
+	name string
+	op   Operator
+}{
+	{"gt", GT},
+	{"gte", GTE},
+	{"lt", LT},
+	{"lte", LTE},
+}
+
+func (c *dslConverter) rangeExpression(path string, body interface{}) *Expression {
+	field, value, err := singleKey(body)
+	if err != nil {
+		c.unsupported(path, err.Error())
+		return nil
+	}
+
+	if !c.field(path, field) {
+		return nil
+	}
+
+	path += "." + field
+
+	m, ok := value.(jmap)
+	if !ok {
+		c.unsupported(path, "expected an object")
+		return nil
+	}
+
+	var result *Expression
+	found := 0
+
+	for _, r := range rangeOperators {
+		if bound, ok := m[r.name]; ok {
+			found++
+
+			if v, ok := dslValue(bound); ok {
+				result = result.And(nameValueExpression(r.op, field, v))
+			} else {
+				c.unsupported(path+"."+r.name, "invalid value")
+			}
+		}
+	}
+
+	if found != len(m) {
+		c.unsupported(path, "options not supported")
+	}
+
+	return result
+}
+
+/*
+ * Return the list of clauses for a bool occurrence type (that can be a single clause or a list)
+ */
+func boolClauses(v interface{}) jarr {
+	if list, ok := v.(jarr); ok {
+		return list
+	}
+
+	return jarr{v}
+}
+
+func (c *dslConverter) boolExpression(path string, body interface{}) *Expression {
+	m, ok := body.(jmap)
+	if !ok {
+		c.unsupported(path, "expected an object")
+		return nil
+	}
+
+	var result, should *Expression
+	required := false // there are must or filter clauses (should clauses are optional)
+	hasShould := false
+	matchAll := false // a should clause matches all the documents (or is not supported)
+
+	for _, occur := range []string{"must", "filter", "must_not", "should"} {
+		for i, clause := range boolClauses(m[occur]) {
+			if clause == nil {
+				continue
+			}
+
+			cpath := fmt.Sprintf("%v.%v[%v]", path, occur, i)
+			nerrors := len(c.errors)
+			e := c.expression(cpath, clause)
+
+			switch occur {
+			case "must", "filter":
+				required = true
+				result = result.And(e)
+
+			case "must_not":
+				// a partial conversion may match more documents, so its negation would match less
+				switch {
+				case len(c.errors) > nerrors:
+					c.unsupported(cpath, "not negated because of unsupported constructs")
+				case e == nil:
+					c.unsupported(cpath, "negated match_all (that matches no documents) not supported")
+				default:
+					result = result.And(e.Not())
+				}
+
+			case "should":
+				hasShould = true
+				if e == nil {
+					matchAll = true
+				} else {
+					should = should.Or(e)
+				}
+			}
+		}
+	}
+
+	for k := range m {
+		switch k {
+		case "must", "filter", "must_not", "should", "minimum_should_match":
+		default:
+			c.unsupported(path+"."+k, "option not supported")
+		}
+	}
+
+	if !hasShould {
+		return result
+	}
+
+	// without must or filter clauses at least one should clause must match
+	msm := 1
+	if required {
+		msm = 0
+	}
+
+	if v, ok := m["minimum_should_match"]; ok {
+		if msm, ok = minimumShouldMatch(v); !ok {
+			c.unsupported(path+".minimum_should_match", "only numbers are supported")
+			return result
+		}
+	}
+
+	switch {
+	case msm <= 0:
+		c.unsupported(path+".should", "optional should clauses not supported")
+		return result
+
+	case matchAll:
+		return result
+
+	case msm > 1:
+		c.unsupported(path+".minimum_should_match", "only 1 is supported")
+	}
+
+	return result.And(should)
+}
+
+/*
+ * Return the value of minimum_should_match, if it's a number (or a string with a number)
+ */
+func minimumShouldMatch(v interface{}) (int, bool) {
+	if s, ok := v.(string); ok {
+		n, err := strconv.Atoi(s)
+		return n, err == nil
+	}
+
+	n, _ := dslValue(v)
+	i, ok := n.(int)
+	return i, ok
+}
+
+func (c *dslConverter) facets(path string, v interface{}) (facets []string) {
+	m, ok := v.(jmap)
+	if !ok {
+		c.unsupported(path, "expected an object")
+		return nil
+	}
+
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		agg, _ := m[name].(jmap)
+		terms, _ := agg["terms"].(jmap)
+
+		if len(agg) != 1 || len(terms) != 1 || terms["field"] != name {
+			c.unsupported(path+"."+name, "only terms aggregations on a field with the same name are supported")
+			continue
+		}
+		if !c.field(path+"."+name, name) {
+			continue
+		}
+
+		facets = append(facets, name)
+	}
+
+	return
+}
+
+func (c *dslConverter) sort(path string, v interface{}) (order []NameValue) {
+	for i, item := range boolClauses(v) {
+		ipath := fmt.Sprintf("%v[%v]", path, i)
+
+		switch s := item.(type) {
+		case string:
+			if c.field(ipath, s) {
+				order = append(order, NameValue{s, ASC.Lower()})
+			}
+
+		case jmap:
+			field, value, err := singleKey(s)
+			if err != nil {
+				c.unsupported(ipath, err.Error())
+				continue
+			}
+			if !c.field(ipath, field) {
+				continue
+			}
+
+			if m, ok := value.(jmap); ok {
+				if _, ok := m["order"]; !ok || len(m) != 1 {
+					c.unsupported(ipath+"."+field, "options not supported")
+					continue
+				}
+
+				value = m["order"]
+			}
+
+			switch dir, _ := value.(string); strings.ToLower(dir) {
+			case "", "asc":
+				order = append(order, NameValue{field, ASC.Lower()})
+			case "desc":
+				order = append(order, NameValue{field, DESC.Lower()})
+			default:
+				c.unsupported(ipath+"."+field, "invalid sort order")
+			}
+
+		default:
+			c.unsupported(ipath, "invalid sort")
+		}
+	}
+
+	return
+}
+
+func (c *dslConverter) source(path string, v interface{}) (fields []string) {
+	switch s := v.(type) {
+	case string:
+		if c.field(path, s) {
+			fields = append(fields, s)
+		}
+
+		return
+
+	case jarr:
+		for i, f := range s {
+			if name, ok := f.(string); ok && c.field(fmt.Sprintf("%v[%v]", path, i), name) {
+				fields = append(fields, name)
+			}
+		}
+
+		return
+
+	case jmap:
+		if _, ok := s["excludes"]; ok || len(s) != 1 {
+			c.unsupported(path, "only includes is supported")
+		}
+
+		return c.source(path+".includes", s["includes"])
+	}
+
+	c.unsupported(path, "not supported")
+	return nil
+}
+
+func (c *dslConverter) highlight(path string, v interface{}) (fields []string) {
+	m, _ := v.(jmap)
+	hf, ok := m["fields"].(jmap)
+	if !ok {
+		c.unsupported(path, "expected fields")
+		return nil
+	}
+
+	for k := range m {
+		if k != "fields" {
+			c.unsupported(path+"."+k, "option not supported")
+		}
+	}
+
+	for name := range hf {
+		if c.field(path+".fields", name) {
+			fields = append(fields, name)
+		}
+	}
+
+	sort.Strings(fields)
+	return
+}
@@ -0,0 +1,116 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromJSON(t *testing.T) {
+	tests := []struct {
+		body        string
+		query       string
+		unsupported []string
+	}{
+		{
+			`{"query": {"match_all": {}}, "from": 10, "size": 5}`,
+			"SELECT * FROM idx LIMIT 10, 5",
+			nil,
+		},
+		{
+			`{"query": {"bool": {"must": [{"term": {"a": "x"}}, {"range": {"n": {"gte": 1, "lt": 10}}}],
+			  "must_not": {"exists": {"field": "z"}}}},
+			  "_source": ["a", "n"], "sort": [{"n": "desc"}, "a"]}`,
+			`SELECT a, n FROM idx WHERE a = "x" AND n >= 1 AND n < 10 AND NOT EXIST z ORDER BY n DESC, a ASC`,
+			[]string{"query.bool.must[0].term.a: exact match converted to a phrase match"},
+		},
+		{
+			`{"query": {"bool": {"should": [{"terms": {"s": ["a", "b"]}}, {"query_string": {"query": "x:1"}}]}},
+			  "aggs": {"f": {"terms": {"field": "f"}}}}`,
+			`SELECT * FACETS f FROM idx WHERE s IN ("a", "b") OR "x:1"`,
+			[]string{"query.bool.should[0].terms.s: exact match converted to a phrase match"},
+		},
+		{
+			`{"query": {"bool": {"must": {"match": {"a": "x"}}, "filter": {"term": {"b": {"value": 1.5}}}}},
+			  "post_filter": {"exists": {"field": "c"}}, "collapse": {"field": "a"}}`,
+			"SELECT * FROM idx WHERE b = 1.5 FILTER EXIST c",
+			[]string{"collapse: not supported", "query.bool.must[0].match: query not supported"},
+		},
+		{
+			`{"query": {"bool": {"must": {"term": {"a": 1}}, "should": {"term": {"b": 2}}}}}`,
+			"SELECT * FROM idx WHERE a = 1",
+			[]string{"query.bool.should: optional should clauses not supported"},
+		},
+		{
+			`{"query": {"bool": {"must_not": {"term": {"a": 1}}, "should": [{"term": {"b": 2}}, {"term": {"c": 3}}]}}}`,
+			"SELECT * FROM idx WHERE NOT a = 1 AND (b = 2 OR c = 3)",
+			nil,
+		},
+		{
+			`{"query": {"bool": {"must": {"term": {"a": 1}}, "should": {"term": {"b": 2}}, "minimum_should_match": "1"}}}`,
+			"SELECT * FROM idx WHERE a = 1 AND b = 2",
+			nil,
+		},
+		{
+			`{"query": {"bool": {"must": {"term": {"a": 1}}, "must_not": {"bool": {"must": [{"term": {"b": 2}}, {"match": {"c": "x"}}]}}}}}`,
+			"SELECT * FROM idx WHERE a = 1",
+			[]string{
+				"query.bool.must_not[0].bool.must[1].match: query not supported",
+				"query.bool.must_not[0]: not negated because of unsupported constructs",
+			},
+		},
+		{
+			`{"query": {"bool": {"should": [{"term": {"a": 1}}, {"match": {"c": "x"}}]}}}`,
+			"SELECT * FROM idx",
+			[]string{"query.bool.should[1].match: query not supported"},
+		},
+		{
+			`{"query": {"bool": {"must": {"term": {"a": 1}}, "must_not": [{"match_all": {}}]}}}`,
+			"SELECT * FROM idx WHERE a = 1",
+			[]string{"query.bool.must_not[0]: negated match_all (that matches no documents) not supported"},
+		},
+		{
+			`{"sort": [{"_script": {"type": "number", "script": "doc.n.value", "order": "asc"}}, {"n": {"order": "desc", "missing": "_last"}}, "a"]}`,
+			"SELECT * FROM idx ORDER BY a ASC",
+			[]string{"sort[0]._script: options not supported", "sort[1].n: options not supported"},
+		},
+		{
+			`{"query": {"bool": {"must": [{"term": {"my-field": 1}}, {"exists": {"field": "@timestamp"}}, {"range": {"n": {"gt": 1}}}]}}, "_source": ["a", "from"]}`,
+			"SELECT a FROM idx WHERE n > 1",
+			[]string{
+				"_source[1]: field name \"from\" is not an identifier",
+				"query.bool.must[0].term: field name \"my-field\" is not an identifier",
+				"query.bool.must[1].exists: field name \"@timestamp\" is not an identifier",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		q, unsupported, err := FromJSON("idx", test.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := FormatString(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if q != expected {
+			t.Errorf("expected\n%v\ngot\n%v", expected, q)
+		}
+		if !reflect.DeepEqual(unsupported, test.unsupported) {
+			t.Errorf("expected unsupported %q, got %q", test.unsupported, unsupported)
+		}
+	}
+
+	for _, index := range []string{"logs-2024", "logs-*", "index"} {
+		q, _, err := FromJSON(index, `{"query": {"term": {"a": 1}}}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed := NewParser(q).Query(); parsed == nil || parsed.Index != index {
+			t.Errorf("%v: unexpected statement %v", index, q)
+		}
+	}
+}
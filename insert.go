@@ -0,0 +1,423 @@
+package elseql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+/*
+ * INSERT statements:
+ *
+ *   INSERT INTO index (a, b, c) VALUES (1, 'x', true), (2, 'y', false)
+ *   INSERT INTO index [(a, b, c)] SELECT ... FROM other ...
+ *
+ * The documents are indexed with the bulk API, in batches of BulkBatchSize documents.
+ * A column (or a selected field) named _id is used as the document id.
+ */
+
+var (
+	// Number of documents sent in each bulk request (and fetched for each page of INSERT INTO ... SELECT)
+	BulkBatchSize = 1000
+
+	bulkErrorColumns = []string{"item", "_id", "status", "error"}
+)
+
+/*
+ * A document that was rejected by a bulk request
+ */
+type BulkError struct {
+	Item   int // position of the document in the list of inserted documents
+	Id     string
+	Status int
+	Reason string
+}
+
+/*
+ * The result of a bulk insert: the number of indexed documents and the list of failed documents
+ */
+type BulkResult struct {
+	Count  int
+	Errors []BulkError
+}
+
+/*
+ * Set the refresh parameter for INSERT (true, false or wait_for), UPDATE and DELETE (true or false) statements
+ */
+func WithRefresh(refresh string) QueryOption {
+	return func(o *queryOptions) {
+		o.refresh = refresh
+	}
+}
+
+func (p *ElseParser) parseInsert() (err error) {
+	if err = p.parseRequired(INTO); err != nil {
+		return
+	}
+	if p.query.Target, err = p.parseIndexName(); err != nil {
+		return
+	}
+
+	if match, _ := p.parseToken('(', true); match {
+		if p.query.Columns, err = p.parseIdentifiers(); err != nil {
+			return
+		}
+
+		err = p.parseParen(CLOSEP)
+	}
+
+	return
+}
+
+/*
+ * Parse the VALUES list or the SELECT statement of an INSERT
+ */
+func (p *ElseParser) parseInsertSource() error {
+	if match, _ := p.parseKeyword(VALUES, true); !match {
+		return p.parseClauses(p.selectClauses())
+	}
+
+	if len(p.query.Columns) == 0 {
+		return ParseError{Pos: p.lastPos, Msg: "INSERT with VALUES requires a list of columns"}
+	}
+
+	p.query.Values = [][]interface{}{}
+
+	for {
+		if err := p.parseParen(OPENP); err != nil {
+			return err
+		}
+
+		row, err := p.parseValues()
+		if err != nil {
+			return err
+		}
+		if len(row) != len(p.query.Columns) {
+			return ParseError{Pos: p.lastPos, Msg: fmt.Sprintf("expected %d values, got %d", len(p.query.Columns), len(row))}
+		}
+
+		if err := p.parseParen(CLOSEP); err != nil {
+			return err
+		}
+
+		p.query.Values = append(p.query.Values, row)
+
+		if match, _ := p.parseToken(list_sep, true); !match {
+			break
+		}
+	}
+
+	if err := p.parseEnd(); err != nil {
+		return err
+	}
+
+	return p.bindParams()
+}
+
+func formatInsert(q *Query) string {
+	s := INSERT.String() + " " + INTO.String() + " " + formatIndexName(q.Target)
+	if len(q.Columns) > 0 {
+		s += " (" + strings.Join(q.Columns, ", ") + ")"
+	}
+
+	if q.Values == nil {
+		source := *q
+		source.Command = SELECT
+		source.Target = ""
+		return s + "\n" + Format(&source)
+	}
+
+	rows := make([]string, 0, len(q.Values))
+	for _, row := range q.Values {
+		values := make([]string, 0, len(row))
+		for _, v := range row {
+			values = append(values, formatValue(v))
+		}
+
+		rows = append(rows, "("+strings.Join(values, ", ")+")")
+	}
+
+	return s + "\n" + VALUES.String() + " " + strings.Join(rows, ",\n  ")
+}
+
+/*
+ * Return the documents for the VALUES rows
+ */
+func (q *Query) valuesDocuments() []map[string]interface{} {
+	docs := make([]map[string]interface{}, 0, len(q.Values))
+
+	for _, row := range q.Values {
+		docs = append(docs, columnsDocument(q.Columns, row))
+	}
+
+	return docs
+}
+
+func columnsDocument(columns []string, values []interface{}) jmap {
+	doc := jmap{}
+	for i, c := range columns {
+		doc[c] = values[i]
+	}
+
+	return doc
+}
+
+/*
+ * Return the body of a bulk request (one index action per document, the _id field is used as document id)
+ */
+func bulkBody(docs []map[string]interface{}) (string, error) {
+	var b strings.Builder
+
+	for _, doc := range docs {
+		action := jmap{}
+
+		if id, ok := doc["_id"]; ok {
+			action["_id"] = stringify(id, "")
+
+			source := make(jmap, len(doc))
+			for k, v := range doc {
+				if k != "_id" {
+					source[k] = v
+				}
+			}
+
+			doc = source
+		}
+
+		for _, v := range []jobj{jmap{"index": action}, doc} {
+			line, err := json.Marshal(v)
+			if err != nil {
+				return "", err
+			}
+
+			b.Write(line)
+			b.WriteByte('\n')
+		}
+	}
+
+	return b.String(), nil
+}
+
+/*
+ * Add the results of a bulk response (offset is the position of the first document of the request)
+ */
+func (r *BulkResult) add(res jmap, offset int) {
+	items, _ := res["items"].(jarr)
+
+	for i, item := range items {
+		_, v, err := singleKey(item)
+		if err != nil {
+			continue
+		}
+
+		m, _ := v.(jmap)
+		status, _ := m["status"].(float64)
+
+		e, failed := m["error"]
+		if !failed {
+			r.Count++
+			continue
+		}
+
+		r.Errors = append(r.Errors, BulkError{
+			Item:   offset + i,
+			Id:     stringify(m["_id"], ""),
+			Status: int(status),
+			Reason: errorReason(e),
+		})
+	}
+}
+
+/*
+ * Return the result of an INSERT statement: the failed documents (if any) and the number of inserted documents
+ */
+func (r *BulkResult) result(nilValue string, returnType ReturnType) (jmap, error) {
+	docs := make([]jmap, 0, len(r.Errors))
+	for _, e := range r.Errors {
+		docs = append(docs, jmap{"item": e.Item, "_id": e.Id, "status": e.Status, "error": e.Reason})
+	}
+
+	if returnType == Full {
+		return jmap{"inserted": r.Count, "errors": docs}, nil
+	}
+
+	data, err := searchResult(nil, docs, bulkErrorColumns, len(r.Errors), nil, nilValue, returnType)
+	if err != nil {
+		return nil, err
+	}
+
+	data["inserted"] = r.Count
+	return data, nil
+}
+
+/*
+ * Send a bulk request for a batch of documents and add the results to result
+ */
+func (es *ElseSearch) bulk(index string, docs []map[string]interface{}, offset int, refresh string, result *BulkResult) error {
+	body, err := bulkBody(docs)
+	if err != nil {
+		return SearchError{Err: err, Query: "POST " + index + "/_bulk"}
+	}
+
+	path := strings.Replace(index, ".", "/", 1) + "/_bulk"
+
+	var params map[string]interface{}
+	if refresh != "" {
+		params = map[string]interface{}{"refresh": refresh}
+	}
+
+	res, err := es.rawRequest("POST", path, params, "application/x-ndjson", body)
+	if err != nil {
+		return err
+	}
+
+	response, _ := res.(jmap)
+	result.add(response, offset)
+	return nil
+}
+
+/*
+ * Index a list of documents, in batches of BulkBatchSize documents.
+ * refresh is the value of the refresh parameter for the bulk requests (true, false, wait_for or empty for the default).
+ * Documents rejected by ElasticSearch are returned in BulkResult.Errors, an error is only returned if a request fails.
+ */
+func (es *ElseSearch) Insert(index string, docs []map[string]interface{}, refresh string) (*BulkResult, error) {
+	result := &BulkResult{}
+
+	for start := 0; start < len(docs); start += BulkBatchSize {
+		end := start + BulkBatchSize
+		if end > len(docs) {
+			end = len(docs)
+		}
+
+		if err := es.bulk(index, docs[start:end], start, refresh, result); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+/*
+ * Copy the documents returned by the SELECT statement of an INSERT, one page (and one bulk request) at a time
+ */
+func (es *ElseSearch) insertSelect(query *Query, queryString string, opts *queryOptions) (*BulkResult, error) {
+	if query.Join != nil || query.Distinct || len(query.FacetList) > 0 {
+		return nil, SearchError{
+			Err:   ParseError{Msg: "JOIN, DISTINCT and FACETS are not supported with INSERT"},
+			Query: queryString,
+		}
+	}
+	if len(query.Columns) > 0 && len(query.Columns) != len(query.SelectList) {
+		return nil, SearchError{
+			Err:   ParseError{Msg: fmt.Sprintf("expected %d selected fields, got %d", len(query.Columns), len(query.SelectList))},
+			Query: queryString,
+		}
+	}
+
+	source := *query
+	source.Command = SELECT
+	source.Target = ""
+	limit := source.Size
+	source.Size = 0 // set for each page
+
+	jq, index, _, err := translateQuery(&source, queryString, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIndex(index, queryString); err != nil {
+		return nil, err
+	}
+
+	if jq["sort"] != nil {
+		jq["sort"] = append(jq["sort"].([]jmap), jmap{"_id": "asc"})
+	} else {
+		jq["sort"] = []jmap{jmap{"_id": "asc"}}
+	}
+
+	result := &BulkResult{}
+	fetched := 0
+
+	for {
+		size := BulkBatchSize
+		if limit >= 0 && limit-fetched < size {
+			size = limit - fetched
+		}
+		if size <= 0 {
+			break
+		}
+
+		jq["size"] = size
+
+		res, err := es.search(index, jq)
+		if err != nil {
+			return result, err
+		}
+
+		hits := res["hits"].(jmap)["hits"].(jarr)
+		docs := make([]map[string]interface{}, 0, len(hits))
+		var last jobj
+
+		for _, h := range hits {
+			doc := hitDocument(h.(jmap), &source, Data)
+			if len(query.Columns) > 0 {
+				values := make([]interface{}, len(query.SelectList))
+				for i, f := range query.SelectList {
+					values[i] = getvalue(doc, f)
+				}
+
+				doc = columnsDocument(query.Columns, values)
+			}
+
+			docs = append(docs, doc)
+			last = h.(jmap)["sort"]
+		}
+
+		if len(docs) > 0 {
+			if err := es.bulk(query.Target, docs, fetched, opts.refresh, result); err != nil {
+				return result, err
+			}
+		}
+
+		fetched += len(docs)
+		if len(hits) < size {
+			break
+		}
+
+		jq["search_after"] = last
+		jq["from"] = 0 // the offset only applies to the first page
+	}
+
+	return result, nil
+}
+
+/*
+ * Execute an INSERT statement
+ */
+func (es *ElseSearch) insert(query *Query, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	if err := checkIndex(query.Target, queryString); err != nil {
+		return nil, err
+	}
+
+	var result *BulkResult
+	var err error
+
+	if query.Values != nil {
+		if err := query.checkParams(); err != nil {
+			return nil, SearchError{
+				Err:   err,
+				Query: queryString,
+			}
+		}
+
+		result, err = es.Insert(query.Target, query.valuesDocuments(), opts.refresh)
+	} else {
+		result, err = es.insertSelect(query, queryString, opts)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.result(nilValue, returnType)
+}
@@ -0,0 +1,122 @@
+package elseql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gobs/simplejson"
+)
+
+func TestParseInsert(t *testing.T) {
+	tests := []struct {
+		statement string
+		target    string
+		columns   []string
+		values    [][]interface{}
+		format    string
+	}{
+		{
+			"INSERT INTO idx (a, b) VALUES (1, 'x'), (-2.5, true)",
+			"idx", []string{"a", "b"}, [][]interface{}{{1, "x"}, {-2.5, true}},
+			"INSERT INTO idx (a, b)\nVALUES (1, \"x\"),\n  (-2.5, true)",
+		},
+		{
+			"insert into 'logs-2024' (_id, msg) values ('1', 'hello')",
+			"logs-2024", []string{"_id", "msg"}, [][]interface{}{{"1", "hello"}},
+			"INSERT INTO \"logs-2024\" (_id, msg)\nVALUES (\"1\", \"hello\")",
+		},
+		{
+			"INSERT INTO idx (values, into) VALUES (1, 2)",
+			"idx", []string{"values", "into"}, [][]interface{}{{1, 2}},
+			"INSERT INTO idx (values, into)\nVALUES (1, 2)",
+		},
+		{
+			"INSERT INTO copy SELECT a, b FROM idx WHERE a > 1 LIMIT 10",
+			"copy", nil, nil,
+			"INSERT INTO copy\nSELECT a, b\nFROM idx\nWHERE a > 1\nLIMIT 10",
+		},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		q := parser.Query()
+		if q.Command != INSERT || q.Target != test.target || !reflect.DeepEqual(q.Columns, test.columns) || !reflect.DeepEqual(q.Values, test.values) {
+			t.Errorf("%v: unexpected query %v", test.statement, q)
+		}
+		if f := Format(q); f != test.format {
+			t.Errorf("%v: expected %q, got %q", test.statement, test.format, f)
+		}
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO idx VALUES (1, 2)",
+		"INSERT INTO idx (a, b) VALUES (1)",
+		"INSERT INTO idx (a) VALUES (1) LIMIT 1",
+		"INSERT idx (a) VALUES (1)",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+
+	parser := NewParser("INSERT INTO idx (a, b) VALUES (?, :name)").Bind(1).BindNamed(map[string]interface{}{"name": "x"})
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if v := parser.Query().Values; !reflect.DeepEqual(v, [][]interface{}{{1, "x"}}) {
+		t.Errorf("unexpected values %v", v)
+	}
+}
+
+func TestBulkBody(t *testing.T) {
+	body, err := bulkBody([]map[string]interface{}{
+		{"_id": 1, "a": "x"},
+		{"b": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"index":{"_id":"1"}}
+{"a":"x"}
+{"index":{}}
+{"b":true}
+`
+	if body != expected {
+		t.Errorf("unexpected body %q", body)
+	}
+
+	if !strings.HasSuffix(body, "\n") {
+		t.Error("the bulk body should end with a newline")
+	}
+}
+
+func TestBulkResult(t *testing.T) {
+	jj, _ := simplejson.LoadString(`{"errors": true, "items": [
+		{"index": {"_id": "1", "status": 201}},
+		{"index": {"_id": "2", "status": 400, "error": {"type": "mapper_parsing_exception", "reason": "failed to parse field [n]"}}}
+	]}`)
+
+	var result BulkResult
+	result.add(jj.MustMap(), 10)
+
+	if result.Count != 1 || len(result.Errors) != 1 {
+		t.Fatalf("unexpected result %v", result)
+	}
+	if e := result.Errors[0]; e.Item != 11 || e.Id != "2" || e.Status != 400 || e.Reason != "mapper_parsing_exception: failed to parse field [n]" {
+		t.Errorf("unexpected error %v", e)
+	}
+
+	res, err := result.result("", List)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["inserted"] != 1 || !reflect.DeepEqual(res["columns"], bulkErrorColumns) || len(res["rows"].(jarr)) != 1 {
+		t.Errorf("unexpected result %v", res)
+	}
+}
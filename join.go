@@ -0,0 +1,201 @@
+package elseql
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gobs/simplejson"
+)
+
+/*
+ * ElasticSearch cannot join indices, so a JOIN statement is executed on the client as a batched lookup join:
+ * a page of documents is fetched from the FROM index, the distinct values of the join field are used to build
+ * a "terms" query on the joined index and the two results are merged (inner join).
+ *
+ * The result rows are documents in the form {leftAlias: leftSource, rightAlias: rightSource},
+ * so that the select list columns (alias.field) can be resolved as usual.
+ */
+
+var (
+	// Maximum number of documents fetched from the FROM index for each page of a JOIN (LIMIT is capped to this value)
+	JoinPageSize = 1000
+
+	// Maximum number of documents fetched from the joined index for each page of a JOIN (additional matches are dropped)
+	JoinMaxMatches = 10000
+)
+
+/*
+ * Return the list of fields to fetch from one side of the join (nil for all fields)
+ */
+func (q *Query) joinFields(alias, key string) (fields []string) {
+	if len(q.SelectList) == 0 {
+		return nil
+	}
+
+	for _, name := range q.SelectList {
+		if a, f := splitAlias(name); a == alias {
+			fields = append(fields, f)
+		}
+	}
+
+	return append(fields, key)
+}
+
+/*
+ * Return the list of join values for a field (that can be a single value or a list)
+ */
+func joinKeys(v jobj) jarr {
+	switch vv := v.(type) {
+	case nil:
+		return nil
+
+	case jarr:
+		return vv
+	}
+
+	return jarr{v}
+}
+
+/*
+ * Limit the page of documents fetched from the FROM index to JoinPageSize
+ */
+func joinPage(jq jmap) {
+	if size, ok := jq["size"].(int); !ok || size > JoinPageSize {
+		jq["size"] = JoinPageSize
+	}
+}
+
+/*
+ * Return the sources of the FROM index hits, the distinct values of the join field
+ * and the sort values of the last hit
+ */
+func (j *Join) leftKeys(hits jarr) (sources []jmap, keys jarr, last jobj) {
+	sources = make([]jmap, 0, len(hits))
+	seen := map[string]bool{}
+
+	for _, r := range hits {
+		source, _ := r.(jmap)["_source"].(jmap)
+		sources = append(sources, source)
+		last = r.(jmap)["sort"]
+
+		for _, k := range joinKeys(getpath(source, j.Left)) {
+			if s := stringify(k, ""); !seen[s] {
+				seen[s] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+
+	return
+}
+
+/*
+ * Return the query for the documents of the joined index matching the join values
+ */
+func (q *Query) joinQuery(keys jarr) jmap {
+	j := q.Join
+
+	rq := jmap{
+		"query": jmap{"terms": jmap{j.Right: keys}},
+		"size":  JoinMaxMatches,
+	}
+
+	if fields := q.joinFields(j.Alias, j.Right); len(fields) > 0 {
+		rq["_source"] = fields
+	}
+
+	return rq
+}
+
+/*
+ * Return the sources of the joined index hits, by join value
+ */
+func (j *Join) rightMatches(hits jarr) map[string][]jmap {
+	matches := map[string][]jmap{}
+
+	for _, r := range hits {
+		source, _ := r.(jmap)["_source"].(jmap)
+
+		for _, k := range joinKeys(getpath(source, j.Right)) {
+			s := stringify(k, "")
+			matches[s] = append(matches[s], source)
+		}
+	}
+
+	return matches
+}
+
+/*
+ * Merge the FROM index documents with the matching documents of the joined index (inner join)
+ */
+func (q *Query) joinRows(sources []jmap, matches map[string][]jmap) []jmap {
+	j := q.Join
+	docs := make([]jmap, 0, len(sources))
+
+	for _, ls := range sources {
+		for _, k := range joinKeys(getpath(ls, j.Left)) {
+			for _, rs := range matches[stringify(k, "")] {
+				docs = append(docs, jmap{q.Alias: ls, j.Alias: rs})
+			}
+		}
+	}
+
+	return docs
+}
+
+/*
+ * Execute a JOIN query. jq is the query for the FROM index, as returned by ParseQuery.
+ * For the Full return type the raw responses are returned as "left" and "right".
+ */
+func (es *ElseSearch) join(query *Query, jq jmap, index string, columns []string, nilValue string, returnType ReturnType) (jmap, error) {
+	j := query.Join
+
+	joinPage(jq)
+
+	left, err := es.search(index, jq)
+	if err != nil {
+		return nil, err
+	}
+
+	lhits := left["hits"].(jmap)
+	lsources, keys, last := j.leftKeys(lhits["hits"].(jarr))
+
+	var right jmap
+	var matches map[string][]jmap
+
+	if len(keys) > 0 {
+		rq := query.joinQuery(keys)
+
+		if Debug {
+			log.Println("JOIN", j.Index, simplejson.MustDumpString(rq))
+		}
+
+		right, err = es.search(strings.Replace(j.Index, ".", "/", 1), rq)
+		if err != nil {
+			return nil, err
+		}
+
+		matches = j.rightMatches(right["hits"].(jmap)["hits"].(jarr))
+	}
+
+	if returnType == Full {
+		return jmap{"left": left, "right": right}, nil
+	}
+
+	docs := query.joinRows(lsources, matches)
+
+	if returnType != Data && len(columns) == 0 && len(docs) > 0 {
+		columns = append(sourceColumns(docs[0][query.Alias].(jmap), query.Alias+"."),
+			sourceColumns(docs[0][j.Alias].(jmap), j.Alias+".")...)
+	}
+
+	data, err := searchResult(left["aggregations"], docs, columns, hitsTotal(lhits), last, nilValue, returnType)
+	if err != nil {
+		return nil, SearchError{
+			Err:   err,
+			Query: simplejson.MustDumpString(jq),
+		}
+	}
+
+	return data, nil
+}
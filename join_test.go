@@ -0,0 +1,69 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJoinPage(t *testing.T) {
+	saved := JoinPageSize
+	defer func() { JoinPageSize = saved }()
+
+	JoinPageSize = 2
+
+	for _, test := range []struct {
+		jq   jmap
+		size int
+	}{
+		{jmap{}, 2},
+		{jmap{"size": 10}, 2},
+		{jmap{"size": 1}, 1},
+	} {
+		if joinPage(test.jq); test.jq["size"] != test.size {
+			t.Errorf("expected size %v, got %v", test.size, test.jq["size"])
+		}
+	}
+}
+
+func TestJoinRows(t *testing.T) {
+	q := NewParser("SELECT o.id, c.name FROM orders o JOIN customers c ON o.customer = c.id").Query()
+	if q == nil {
+		t.Fatal("parse failed")
+	}
+
+	left := jarr{
+		jmap{"_source": jmap{"id": 1.0, "customer": "a"}, "sort": jarr{1.0}},
+		jmap{"_source": jmap{"id": 2.0, "customer": jarr{"a", "b"}}, "sort": jarr{2.0}},
+		jmap{"_source": jmap{"id": 3.0, "customer": "x"}, "sort": jarr{3.0}},
+	}
+
+	sources, keys, last := q.Join.leftKeys(left)
+	if len(sources) != 3 || !reflect.DeepEqual(keys, jarr{"a", "b", "x"}) || !reflect.DeepEqual(last, jarr{3.0}) {
+		t.Fatalf("unexpected left side %v %v %v", sources, keys, last)
+	}
+
+	expected := jmap{
+		"query":   jmap{"terms": jmap{"id": keys}},
+		"size":    JoinMaxMatches,
+		"_source": []string{"name", "id"},
+	}
+	if rq := q.joinQuery(keys); !reflect.DeepEqual(rq, expected) {
+		t.Errorf("unexpected join query %v", rq)
+	}
+
+	right := jarr{
+		jmap{"_source": jmap{"id": "a", "name": "A"}},
+		jmap{"_source": jmap{"id": "b", "name": "B"}},
+	}
+
+	rows := q.joinRows(sources, q.Join.rightMatches(right))
+	expectedRows := []jmap{
+		{"o": sources[0], "c": jmap{"id": "a", "name": "A"}},
+		{"o": sources[1], "c": jmap{"id": "a", "name": "A"}},
+		{"o": sources[1], "c": jmap{"id": "b", "name": "B"}},
+	}
+
+	if !reflect.DeepEqual(rows, expectedRows) {
+		t.Errorf("unexpected rows %v", rows)
+	}
+}
@@ -0,0 +1,480 @@
+package elseql
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+/*
+ * Index mappings and mapping-aware validation.
+ *
+ * The mapping of an index (GET index/_mapping) is flattened to a map of field paths (a.b.c) to field types,
+ * with multi-fields (i.e. name.keyword) as separate entries. Mappings are cached by ElseSearch.
+ *
+ * Validate checks that the fields referenced in the query exist, that the values in WHERE/FILTER comparisons
+ * match the field types and that text fields are not used for sorting, facets or DISTINCT.
+ */
+
+type FieldMapping struct {
+	Type   string
+	Fields []string // multi-fields (i.e. "keyword" for name.keyword)
+}
+
+/*
+ * Flattened mapping: field path -> field mapping
+ */
+type Mapping map[string]FieldMapping
+
+var numericTypes = map[string]bool{
+	"long":          true,
+	"integer":       true,
+	"short":         true,
+	"byte":          true,
+	"double":        true,
+	"float":         true,
+	"half_float":    true,
+	"scaled_float":  true,
+	"unsigned_long": true,
+}
+
+/*
+ * Add the fields in a "properties" object to the mapping
+ */
+func (m Mapping) addProperties(prefix string, properties jmap) {
+	for name, v := range properties {
+		def, _ := v.(jmap)
+		path := prefix + name
+
+		typ, _ := def["type"].(string)
+
+		if props, ok := def["properties"].(jmap); ok {
+			if typ == "" {
+				typ = "object"
+			}
+
+			m[path] = FieldMapping{Type: typ}
+			m.addProperties(path+".", props)
+			continue
+		}
+
+		fm := FieldMapping{Type: typ}
+
+		if fields, ok := def["fields"].(jmap); ok {
+			for sub, sv := range fields {
+				sdef, _ := sv.(jmap)
+				stype, _ := sdef["type"].(string)
+
+				fm.Fields = append(fm.Fields, sub)
+				m[path+"."+sub] = FieldMapping{Type: stype}
+			}
+
+			sort.Strings(fm.Fields)
+		}
+
+		if _, ok := m[path]; !ok { // the first index wins
+			m[path] = fm
+		}
+	}
+}
+
+/*
+ * Create a mapping from a GET _mapping response (the mappings of all the returned indices are merged)
+ */
+func NewMapping(response map[string]interface{}) Mapping {
+	m := Mapping{}
+
+	indices := make([]string, 0, len(response))
+	for index := range response {
+		indices = append(indices, index)
+	}
+	sort.Strings(indices)
+
+	for _, index := range indices {
+		def, _ := response[index].(jmap)
+		mappings, _ := def["mappings"].(jmap)
+
+		if props, ok := mappings["properties"].(jmap); ok {
+			m.addProperties("", props)
+			continue
+		}
+
+		for _, t := range mappings { // mapping types (ES 6 and earlier)
+			if tdef, ok := t.(jmap); ok {
+				if props, ok := tdef["properties"].(jmap); ok {
+					m.addProperties("", props)
+				}
+			}
+		}
+	}
+
+	return m
+}
+
+/*
+ * Return the (cached) mapping for an index (in ElseSQL form, index.type or _all)
+ */
+func (es *ElseSearch) Mapping(index string) (Mapping, error) {
+	if i := strings.Index(index, "."); i > 0 { // drop the type
+		index = index[:i]
+	}
+	if index == "" {
+		index = "_all"
+	}
+
+	es.mu.Lock()
+	m, ok := es.mappings[index]
+	es.mu.Unlock()
+
+	if ok {
+		return m, nil
+	}
+
+	res, err := es.request("GET", index+"/_mapping", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, _ := res.(jmap)
+	m = NewMapping(response)
+
+	es.mu.Lock()
+	if es.mappings == nil {
+		es.mappings = map[string]Mapping{}
+	}
+	es.mappings[index] = m
+	es.mu.Unlock()
+
+	return m, nil
+}
+
+/*
+ * Remove the cached mappings (i.e. after a mapping change)
+ */
+func (es *ElseSearch) ClearMappings() {
+	es.mu.Lock()
+	es.mappings = nil
+	es.mu.Unlock()
+}
+
+/*
+ * Validate the query against the index mapping, before sending the search request.
+ * Only used by ElseSearch.Search and ElseSearch.SearchQuery: validation errors are returned as errors.
+ */
+func WithValidation() QueryOption {
+	return func(o *queryOptions) {
+		o.validate = true
+	}
+}
+
+/*
+ * Parse the query and validate it against the index mapping. Returns the list of errors and warnings
+ * (syntax errors, unknown fields, type mismatches...). JOIN queries and commands are not validated.
+ */
+func (es *ElseSearch) Validate(queryString string, options ...QueryOption) ([]Diagnostic, error) {
+	opts := getQueryOptions(options)
+	parser := NewParser(queryString).Bind(opts.args...).BindNamed(opts.params)
+
+	query, diagnostics := parser.ParseDiagnostics()
+	if hasErrors(diagnostics) || query.Join != nil || query.isCommand() {
+		return diagnostics, nil
+	}
+
+	mapping, err := es.Mapping(query.Index)
+	if err != nil {
+		return diagnostics, err
+	}
+
+	diagnostics = append(diagnostics, mapping.validate(query, parser.fieldPos)...)
+	sortDiagnostics(diagnostics)
+	return diagnostics, nil
+}
+
+func hasErrors(diagnostics []Diagnostic) bool {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+ * Return the first error in the diagnostics as a SearchError (or nil)
+ */
+func validationError(queryString string, diagnostics []Diagnostic) error {
+	for _, d := range diagnostics {
+		if d.Severity == SeverityError {
+			return SearchError{
+				Err:   ParseError{Pos: d.Pos, Msg: d.Msg},
+				Query: queryString,
+			}
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Validate the query (without positions, for queries that were not parsed)
+ */
+func (m Mapping) Validate(q *Query) []Diagnostic {
+	return m.validate(q, nil)
+}
+
+func (m Mapping) validate(q *Query, positions map[Keyword]map[string]scanner.Position) (diagnostics []Diagnostic) {
+	if q.Join != nil {
+		return nil
+	}
+
+	var clause Keyword
+
+	report := func(severity Severity, field, msg string, args ...interface{}) {
+		diagnostics = append(diagnostics, Diagnostic{
+			Pos:      positions[clause][field],
+			Severity: severity,
+			Msg:      fmt.Sprintf(msg, args...),
+		})
+	}
+
+	// return the field mapping, reporting unknown fields
+	lookup := func(field string) (FieldMapping, bool) {
+		if metaFields[field] || strings.Contains(field, "*") {
+			return FieldMapping{}, false
+		}
+
+		fm, ok := m[field]
+		if !ok {
+			report(SeverityError, field, "unknown field %v in %v", field, q.Index)
+		}
+
+		return fm, ok
+	}
+
+	// check that a field can be used for sorting and aggregations
+	exact := func(field, clause string) {
+		if fm, ok := lookup(field); ok && fm.Type == "text" {
+			if hasString(fm.Fields, "keyword") {
+				report(SeverityError, field, "text field %v cannot be used in %v, use %v.keyword", field, clause, field)
+			} else {
+				report(SeverityError, field, "text field %v cannot be used in %v", field, clause)
+			}
+		}
+	}
+
+	clause = SELECT
+	for _, f := range q.SelectList {
+		if q.Distinct {
+			exact(f, DISTINCT.String())
+		} else {
+			lookup(f)
+		}
+	}
+
+	clause = FACETS
+	for _, f := range q.FacetList {
+		exact(f, FACETS.String())
+	}
+
+	clause = HIGHLIGHT
+	for _, f := range q.HighlightList {
+		lookup(f)
+	}
+
+	clause = ORDER
+	for _, nv := range q.OrderList {
+		switch nv.Name {
+		case "_script", "_doc":
+		default:
+			exact(nv.Name, ORDER.String()+" "+BY.String())
+		}
+	}
+
+	exprs := map[Keyword]*Expression{WHERE: q.WhereExpr, FILTER: q.FilterExpr}
+
+	for _, clause = range []Keyword{WHERE, FILTER} {
+		Walk(exprs[clause].AST(), func(n Node) bool {
+			switch n := n.(type) {
+			case *Exists:
+				lookup(n.Field)
+
+			case *Predicate:
+				fm, ok := lookup(n.Field)
+				if !ok {
+					break
+				}
+
+				values := []interface{}{n.Value}
+				if n.Op == IN {
+					values, _ = n.Value.([]interface{})
+				}
+
+				for _, v := range values {
+					if msg := checkValue(fm.Type, v); msg != "" {
+						report(SeverityError, n.Field, "%v for %v field %v", msg, fm.Type, n.Field)
+						break
+					}
+				}
+
+				if fm.Type == "text" && n.Op != EQ && n.Op != NE && n.Op != IN {
+					report(SeverityWarning, n.Field, "range on text field %v compares analyzed terms", n.Field)
+				}
+			}
+
+			return true
+		})
+	}
+
+	return
+}
+
+/*
+ * Check that a value can be compared with a field of the specified type. Returns an error message or "".
+ */
+func checkValue(typ string, v interface{}) string {
+	switch v.(type) {
+	case Param, Raw, nil:
+		return ""
+	}
+
+	if s, ok := v.(string); ok && s == "" { // field = '' matches any value
+		return ""
+	}
+
+	switch {
+	case numericTypes[typ]:
+		switch vv := v.(type) {
+		case int, float64:
+			return ""
+		case string:
+			if _, err := strconv.ParseFloat(vv, 64); err == nil {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("invalid number %v", formatValue(v))
+
+	case typ == "date" || typ == "date_nanos":
+		if _, ok := v.(bool); ok {
+			return fmt.Sprintf("invalid date %v", formatValue(v))
+		}
+
+	case typ == "boolean":
+		switch vv := v.(type) {
+		case bool:
+			return ""
+		case string:
+			if vv == "true" || vv == "false" {
+				return ""
+			}
+		}
+
+		return fmt.Sprintf("invalid boolean %v", formatValue(v))
+	}
+
+	return ""
+}
+
+/*
+ * Rewrite exact-match predicates (comparisons and IN), ORDER BY and FACETS on text fields to use their
+ * keyword sub-field, according to the mapping. Full-text predicates (query strings) are not changed.
+ * ElseSearch.Search and ElseSearch.SearchQuery fetch the index mapping if m is nil.
+ */
+func WithKeywordFields(m Mapping) QueryOption {
+	return func(o *queryOptions) {
+		o.keywordFields = true
+		o.mapping = m
+	}
+}
+
+/*
+ * Return the keyword sub-field for a text field (or "" if the field is not a text field with a keyword sub-field)
+ */
+func (m Mapping) KeywordField(field string) string {
+	fm, ok := m[field]
+	if !ok || fm.Type != "text" {
+		return ""
+	}
+
+	if hasString(fm.Fields, "keyword") && m[field+".keyword"].Type == "keyword" {
+		return field + ".keyword"
+	}
+
+	for _, sub := range fm.Fields {
+		if m[field+"."+sub].Type == "keyword" {
+			return field + "." + sub
+		}
+	}
+
+	return ""
+}
+
+/*
+ * Replace text fields with their keyword sub-field in exact-match predicates (=, != and IN), sorts and facets
+ * (see WithKeywordFields). Facets keep their name, only the field of the aggregation is changed.
+ */
+func (q *Query) UseKeywordFields(m Mapping) {
+	if q.Join != nil {
+		return
+	}
+
+	keyword := func(field string) string {
+		if kf := m.KeywordField(field); kf != "" {
+			return kf
+		}
+
+		return field
+	}
+
+	rewrite := func(n Node) Node {
+		if p, ok := n.(*Predicate); ok {
+			switch p.Op {
+			case EQ, NE, IN:
+				p.Field = keyword(p.Field)
+			}
+		}
+
+		return n
+	}
+
+	q.WhereExpr = q.WhereExpr.Rewrite(rewrite)
+	q.FilterExpr = q.FilterExpr.Rewrite(rewrite)
+
+	if len(q.FacetList) > 0 {
+		fields := map[string]string{}
+		for f, field := range q.FacetFields {
+			fields[f] = field
+		}
+
+		for _, f := range q.FacetList {
+			if kf := m.KeywordField(f); kf != "" {
+				fields[f] = kf
+			}
+		}
+
+		q.FacetFields = fields
+	}
+
+	if len(q.OrderList) > 0 {
+		order := make([]NameValue, 0, len(q.OrderList))
+		for _, nv := range q.OrderList {
+			order = append(order, NameValue{keyword(nv.Name), nv.Value})
+		}
+		q.OrderList = order
+	}
+}
+
+/*
+ * Return the mapping of the index of a query (nil for invalid or JOIN queries)
+ */
+func (es *ElseSearch) queryMapping(queryString string) (Mapping, error) {
+	parser := NewParser(queryString)
+	if err := parser.Parse(); err != nil || parser.Query().Join != nil || parser.Query().isCommand() {
+		return nil, nil // errors are reported by the search
+	}
+
+	return es.Mapping(parser.Query().Index)
+}
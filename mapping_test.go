@@ -0,0 +1,115 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gobs/simplejson"
+)
+
+const testMapping = `{
+  "idx": {
+    "mappings": {
+      "properties": {
+        "name": {"type": "text", "fields": {"keyword": {"type": "keyword"}}},
+        "count": {"type": "long"},
+        "ts": {"type": "date"},
+        "active": {"type": "boolean"},
+        "user": {"properties": {"id": {"type": "keyword"}}}
+      }
+    }
+  }
+}`
+
+func loadTestMapping(t *testing.T) Mapping {
+	jj, err := simplejson.LoadString(testMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewMapping(jj.MustMap())
+}
+
+func TestMapping(t *testing.T) {
+	m := loadTestMapping(t)
+
+	expected := Mapping{
+		"name":         {Type: "text", Fields: []string{"keyword"}},
+		"name.keyword": {Type: "keyword"},
+		"count":        {Type: "long"},
+		"ts":           {Type: "date"},
+		"active":       {Type: "boolean"},
+		"user":         {Type: "object"},
+		"user.id":      {Type: "keyword"},
+	}
+
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("expected %v, got %v", expected, m)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := loadTestMapping(t)
+
+	parser := NewParser(`SELECT name, nmae FACETS name FROM idx
+WHERE count > 'abc' AND user.id = 'x' AND name > 'a' AND active = true
+ORDER BY ts DESC, name`)
+
+	query, diagnostics := parser.ParseDiagnostics()
+	if len(diagnostics) > 0 {
+		t.Fatal(diagnostics)
+	}
+
+	diagnostics = m.validate(query, parser.fieldPos)
+	sortDiagnostics(diagnostics)
+
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+
+	expected := []string{
+		"1:14: error: unknown field nmae in idx",
+		"1:26: error: text field name cannot be used in FACETS, use name.keyword",
+		`2:7: error: invalid number "abc" for long field count`,
+		"2:43: warning: range on text field name compares analyzed terms",
+		"3:19: error: text field name cannot be used in ORDER BY, use name.keyword",
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected\n%q\ngot\n%q", expected, got)
+	}
+}
+
+func TestKeywordFields(t *testing.T) {
+	m := loadTestMapping(t)
+
+	jq, _, _, err := ParseQuery("SELECT * FACETS name, count FROM idx WHERE name = 'Joe' AND name IN ('a', 'b') AND name > 'J' AND 'name:joe' ORDER BY name DESC", "",
+		WithKeywordFields(m), WithoutOptimizer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q := jq["query"].(jmap)["query_string"].(jmap)["query"]; q != `name.keyword:"Joe" AND name.keyword:("a" OR "b") AND name:{"J" TO *} AND name:joe` {
+		t.Errorf("unexpected query %v", q)
+	}
+
+	aggs := jq["aggs"].(jmap)
+	if agg, ok := aggs["name"].(jmap); !ok || agg["terms"].(jmap)["field"] != "name.keyword" || aggs["count"] == nil {
+		t.Errorf("unexpected aggs %v", aggs)
+	}
+
+	if sort := jq["sort"].([]jmap); sort[0]["name.keyword"] != "desc" {
+		t.Errorf("unexpected sort %v", sort)
+	}
+
+	// without mapping the query is unchanged
+	jq, _, _, err = ParseQuery("SELECT * FROM idx ORDER BY name", "", WithKeywordFields(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sort := jq["sort"].([]jmap); sort[0]["name"] != "asc" {
+		t.Errorf("unexpected sort %v", sort)
+	}
+}
@@ -0,0 +1,230 @@
+package elseql
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+ * Metadata statements:
+ *
+ *   SHOW INDICES [LIKE 'pattern'] (% or * match any sequence of characters, _ a single character)
+ *   SHOW ALIASES
+ *   SHOW STATS index
+ *   DESCRIBE index
+ *
+ * The results have the same columns/rows shape as the search results.
+ */
+
+var (
+	indicesColumns  = []string{"index", "health", "status", "docs.count", "store.size", "pri", "rep"}
+	aliasesColumns  = []string{"alias", "index", "filter", "is_write_index"}
+	statsColumns    = []string{"index", "docs.count", "docs.deleted", "store.size_in_bytes", "indexing.index_total", "search.query_total"}
+	describeColumns = []string{"field", "type", "fields"}
+)
+
+func (p *ElseParser) parseShow() (err error) {
+	switch p.query.Show = p.parseKeywords([]Keyword{INDICES, ALIASES, STATS}, NO_KEYWORD); p.query.Show {
+	case INDICES:
+		if match, _ := p.parseKeyword(LIKE, true); match {
+			p.query.Pattern, err = p.parseString()
+		}
+
+	case STATS:
+		p.query.Index, err = p.parseIndexName()
+
+	case ALIASES:
+
+	default:
+		err = p.parseError(INDICES.String(), ALIASES.String(), STATS.String())
+	}
+
+	return
+}
+
+func (p *ElseParser) parseDescribe() (err error) {
+	p.query.Index, err = p.parseIndexName()
+	return
+}
+
+func formatShow(q *Query) string {
+	s := SHOW.String() + " " + q.Show.String()
+
+	switch q.Show {
+	case INDICES:
+		if q.Pattern != "" {
+			s += " " + LIKE.String() + " " + formatValue(q.Pattern)
+		}
+
+	case STATS:
+		s += " " + formatIndexName(q.Index)
+	}
+
+	return s
+}
+
+/*
+ * Convert a list of objects (i.e. a _cat response) to a result
+ */
+func listResult(res jobj, columns []string, nilValue string, returnType ReturnType) (jmap, error) {
+	if returnType == Full {
+		return fullResult(res), nil
+	}
+
+	list, _ := res.(jarr)
+
+	docs := make([]jmap, 0, len(list))
+	for _, item := range list {
+		if m, ok := item.(jmap); ok {
+			docs = append(docs, m)
+		}
+	}
+
+	return searchResult(nil, docs, columns, len(docs), nil, nilValue, returnType)
+}
+
+/*
+ * Convert a LIKE pattern to an index pattern (% and _ are replaced with *) and to a regular expression
+ * that matches the index names (_cat/indices has no wildcard for a single character)
+ */
+func likePattern(pattern string) (string, *regexp.Regexp) {
+	var index, expr strings.Builder
+
+	expr.WriteString("^")
+	for _, c := range pattern {
+		switch c {
+		case '%', '*':
+			index.WriteRune('*')
+			expr.WriteString(".*")
+
+		case '_':
+			index.WriteRune('*')
+			expr.WriteString(".")
+
+		default:
+			index.WriteRune(c)
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	return index.String(), regexp.MustCompile(expr.String())
+}
+
+/*
+ * Return the list of indices (matching pattern, if not empty), from _cat/indices
+ */
+func (es *ElseSearch) ShowIndices(pattern, nilValue string, returnType ReturnType) (jmap, error) {
+	var match *regexp.Regexp
+
+	path := "_cat/indices"
+	if pattern != "" {
+		var index string
+
+		index, match = likePattern(pattern)
+		path += "/" + url.PathEscape(index)
+	}
+
+	res, err := es.request("GET", path, map[string]interface{}{"format": "json", "s": "index"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if list, ok := res.(jarr); ok && match != nil {
+		matches := make(jarr, 0, len(list))
+		for _, item := range list {
+			if m, ok := item.(jmap); ok {
+				if name, _ := m["index"].(string); match.MatchString(name) {
+					matches = append(matches, item)
+				}
+			}
+		}
+
+		res = matches
+	}
+
+	return listResult(res, indicesColumns, nilValue, returnType)
+}
+
+/*
+ * Return the list of aliases, from _cat/aliases
+ */
+func (es *ElseSearch) ShowAliases(nilValue string, returnType ReturnType) (jmap, error) {
+	res, err := es.request("GET", "_cat/aliases", map[string]interface{}{"format": "json", "s": "alias"}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return listResult(res, aliasesColumns, nilValue, returnType)
+}
+
+/*
+ * Return document, store, indexing and search statistics for an index (or index pattern), from _stats
+ */
+func (es *ElseSearch) ShowStats(index, nilValue string, returnType ReturnType) (jmap, error) {
+	res, err := es.request("GET", index+"/_stats", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if returnType == Full {
+		return fullResult(res), nil
+	}
+
+	response, _ := res.(jmap)
+	indices, _ := response["indices"].(jmap)
+
+	names := make([]string, 0, len(indices))
+	for name := range indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	docs := make([]jmap, 0, len(names))
+	for _, name := range names {
+		stats, _ := indices[name].(jmap)
+		total, _ := stats["total"].(jmap)
+
+		doc := jmap{"index": name}
+		for _, c := range statsColumns[1:] {
+			doc[c] = getpath(total, c)
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return searchResult(nil, docs, statsColumns, len(docs), nil, nilValue, returnType)
+}
+
+/*
+ * Return the flattened mapping of an index: field path, type and sub-fields
+ */
+func (es *ElseSearch) Describe(index, nilValue string, returnType ReturnType) (jmap, error) {
+	mapping, err := es.Mapping(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return mapping.result(nilValue, returnType)
+}
+
+func (m Mapping) result(nilValue string, returnType ReturnType) (jmap, error) {
+	fields := make([]string, 0, len(m))
+	for f := range m {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	docs := make([]jmap, 0, len(fields))
+	for _, f := range fields {
+		docs = append(docs, jmap{
+			"field":  f,
+			"type":   m[f].Type,
+			"fields": strings.Join(m[f].Fields, ","),
+		})
+	}
+
+	return searchResult(nil, docs, describeColumns, len(docs), nil, nilValue, returnType)
+}
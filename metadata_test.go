@@ -0,0 +1,75 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gobs/simplejson"
+)
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		statement string
+		show      Keyword
+		index     string
+		pattern   string
+		format    string
+	}{
+		{"SHOW INDICES", INDICES, "", "", "SHOW INDICES"},
+		{"show indices like 'logs-%'", INDICES, "", "logs-%", `SHOW INDICES LIKE "logs-%"`},
+		{"SHOW ALIASES", ALIASES, "", "", "SHOW ALIASES"},
+		{"SHOW STATS 'logs-*'", STATS, "logs-*", "", `SHOW STATS "logs-*"`},
+		{"DESCRIBE idx", 0, "idx", "", "DESCRIBE idx"},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		q := parser.Query()
+		if !q.isCommand() || q.Show != test.show || q.Index != test.index || q.Pattern != test.pattern {
+			t.Errorf("%v: unexpected query %v", test.statement, q)
+		}
+		if f := Format(q); f != test.format {
+			t.Errorf("%v: expected %q, got %q", test.statement, test.format, f)
+		}
+	}
+
+	if err := NewParser("SHOW TABLES").Parse(); err == nil {
+		t.Error("expected error for SHOW TABLES")
+	}
+
+	if _, _, _, err := ParseQuery("DESCRIBE idx", ""); err == nil {
+		t.Error("expected error for ParseQuery(DESCRIBE)")
+	}
+}
+
+func TestMetadataResults(t *testing.T) {
+	res, err := loadTestMapping(t).result("", List)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := res["rows"].(jarr)
+	if len(rows) != 7 || !reflect.DeepEqual(rows[2], jarr{"name", "text", "keyword"}) {
+		t.Errorf("unexpected rows %v", rows)
+	}
+
+	jj, _ := simplejson.LoadString(`[{"index": "a", "health": "green", "status": "open", "docs.count": "10", "store.size": "1kb", "pri": "1", "rep": "0"}]`)
+
+	res, err = listResult(jj.Data(), indicesColumns, "", StringList)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(res["rows"], jarr{jarr{"a", "green", "open", "10", "1kb", "1", "0"}}) {
+		t.Errorf("unexpected rows %v", res["rows"])
+	}
+
+	index, match := likePattern("logs_2024.%")
+	if index != "logs*2024.*" || !match.MatchString("logs-2024.01") || match.MatchString("logs--2024.01") || match.MatchString("logs-2024x01") {
+		t.Errorf("unexpected pattern %q %v", index, match)
+	}
+}
@@ -0,0 +1,663 @@
+package elseql
+
+import (
+	"fmt"
+)
+
+/*
+ * Query optimizer.
+ *
+ * The parser returns the WHERE and FILTER expressions as written. Before the translation the expressions
+ * are simplified with these rules, applied until nothing changes:
+ *
+ *   - flatten: a AND (b AND c) -> a AND b AND c (same for OR)
+ *   - not: NOT NOT a -> a, NOT a = 1 -> a != 1, NOT a != 1 -> a = 1
+ *   - de morgan: NOT (a OR b) -> NOT a AND NOT b (NOT (a AND b) is left as is, since Lucene would read
+ *     NOT a OR NOT b as NOT a AND NOT b)
+ *   - merge ranges: x > 1 AND x < 5 -> x:{1 TO 5}, x > 1 AND x > 3 -> x > 3 (only numeric bounds are merged
+ *     and bounds that no value can match, like x > 5 AND x < 2, are left as written)
+ *   - terms: a = 1 OR a = 2 OR a IN (3, 4) -> a IN (1, 2, 3, 4)
+ *
+ * In the translation the top level AND operands that don't affect scoring (ranges, exact matches on
+ * non-string values, EXIST, negations) are moved to the filter context of a bool query.
+ *
+ * Use WithoutOptimizer to translate the query as written.
+ */
+
+/*
+ * Translate the query as written, without optimizations
+ */
+func WithoutOptimizer() QueryOption {
+	return func(o *queryOptions) {
+		o.noOptimizer = true
+	}
+}
+
+/*
+ * Lower and upper bounds of a range (nil for unbounded), the result of merging comparisons on the same field
+ */
+type Range struct {
+	Lower, Upper               interface{}
+	IncludeLower, IncludeUpper bool
+}
+
+func (r Range) lowerOperator() Operator {
+	if r.IncludeLower {
+		return GTE
+	}
+
+	return GT
+}
+
+func (r Range) upperOperator() Operator {
+	if r.IncludeUpper {
+		return LTE
+	}
+
+	return LT
+}
+
+/*
+ * Return the range in Lucene syntax ({1 TO 5], [* TO 10}...)
+ */
+func (r Range) QueryString() string {
+	lower, upper := "{*", "*}"
+
+	if r.Lower != nil {
+		if r.IncludeLower {
+			lower = "[" + queryValue(r.Lower)
+		} else {
+			lower = "{" + queryValue(r.Lower)
+		}
+	}
+
+	if r.Upper != nil {
+		if r.IncludeUpper {
+			upper = queryValue(r.Upper) + "]"
+		} else {
+			upper = queryValue(r.Upper) + "}"
+		}
+	}
+
+	return lower + " TO " + upper
+}
+
+/*
+ * A rule applied by the optimizer
+ */
+type OptimizerStep struct {
+	Rule   string
+	Before string
+	After  string
+}
+
+func (s OptimizerStep) String() string {
+	return s.Rule + ": " + s.Before + " -> " + s.After
+}
+
+type optimizer struct {
+	steps []OptimizerStep
+}
+
+// maximum number of passes over an expression
+const optimizerPasses = 10
+
+/*
+ * Optimize the WHERE and FILTER expressions of the query (in place) and return the list of rules applied
+ */
+func (q *Query) Optimize() []OptimizerStep {
+	o := optimizer{}
+
+	q.WhereExpr = o.optimize(q.WhereExpr)
+	q.FilterExpr = o.optimize(q.FilterExpr)
+	return o.steps
+}
+
+func (o *optimizer) optimize(e *Expression) *Expression {
+	if e == nil {
+		return nil
+	}
+
+	n := e.AST()
+
+	for i := 0; i < optimizerPasses; i++ {
+		nsteps := len(o.steps)
+
+		n = Rewrite(n, o.rewrite)
+		if len(o.steps) == nsteps {
+			break
+		}
+	}
+
+	return NewExpression(n)
+}
+
+func nodeString(n Node) string {
+	e := NewExpression(n)
+
+	switch {
+	case e == nil:
+		return ""
+	case e.op == RANGE_EXPR: // don't show a range as an AND
+		return formatOperand(e)
+	}
+
+	return formatExpression(e)
+}
+
+/*
+ * Apply rule to the node and record a step if the node was replaced
+ */
+func (o *optimizer) apply(name string, n Node, rule func(Node) Node) Node {
+	before := nodeString(n)
+
+	if nn := rule(n); nn != nil {
+		o.steps = append(o.steps, OptimizerStep{Rule: name, Before: before, After: nodeString(nn)})
+		return nn
+	}
+
+	return n
+}
+
+func (o *optimizer) rewrite(n Node) Node {
+	switch n.(type) {
+	case *Not:
+		n = o.apply("not", n, simplifyNot)
+		n = o.apply("de morgan", n, deMorgan)
+
+	case *Boolean:
+		n = o.apply("flatten", n, flatten)
+		n = o.apply("merge ranges", n, mergeRanges)
+		n = o.apply("terms", n, mergeEqualities)
+	}
+
+	return n
+}
+
+//
+// Rules: each rule returns the replacement node or nil if the rule doesn't apply
+//
+
+func simplifyNot(n Node) Node {
+	not, ok := n.(*Not)
+	if !ok {
+		return nil
+	}
+
+	switch op := not.Operand.(type) {
+	case *Not:
+		return op.Operand
+
+	case *Predicate:
+		switch op.Op {
+		case EQ:
+			return &Predicate{Field: op.Field, Op: NE, Value: op.Value}
+		case NE:
+			return &Predicate{Field: op.Field, Op: EQ, Value: op.Value}
+		}
+	}
+
+	return nil
+}
+
+func deMorgan(n Node) Node {
+	not, ok := n.(*Not)
+	if !ok {
+		return nil
+	}
+
+	b, ok := not.Operand.(*Boolean)
+	if !ok || b.Op != OP_OR {
+		return nil
+	}
+
+	nb := &Boolean{Op: OP_AND}
+
+	for _, op := range b.Operands {
+		nb.Operands = append(nb.Operands, &Not{Operand: op})
+	}
+
+	return nb
+}
+
+func flatten(n Node) Node {
+	b, ok := n.(*Boolean)
+	if !ok {
+		return nil
+	}
+
+	if len(b.Operands) == 1 {
+		return b.Operands[0]
+	}
+
+	var operands []Node
+	changed := false
+
+	for _, op := range b.Operands {
+		if ob, ok := op.(*Boolean); ok && ob.Op == b.Op {
+			operands = append(operands, ob.Operands...)
+			changed = true
+		} else {
+			operands = append(operands, op)
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return &Boolean{Op: b.Op, Operands: operands}
+}
+
+/*
+ * Return the value of a numeric bound
+ */
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	return 0, false
+}
+
+/*
+ * Compare two bound values: returns -1, 0, 1 and true if the values are comparable.
+ * Only numbers are compared: strings and dates are compared by ElasticSearch according to the field type.
+ */
+func compareValues(a, b interface{}) (int, bool) {
+	na, ok := numberValue(a)
+	if !ok {
+		return 0, false
+	}
+
+	nb, ok := numberValue(b)
+	if !ok {
+		return 0, false
+	}
+
+	switch {
+	case na < nb:
+		return -1, true
+	case na > nb:
+		return 1, true
+	}
+
+	return 0, true
+}
+
+/*
+ * Add a comparison to a range (a nil value is ignored). Returns false if the bound is not a number.
+ */
+func (r *Range) add(op Operator, v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	if _, ok := numberValue(v); !ok {
+		return false
+	}
+
+	switch op {
+	case GT, GTE:
+		if r.Lower != nil {
+			c, ok := compareValues(v, r.Lower)
+			if !ok {
+				return false
+			}
+			if c < 0 || (c == 0 && !r.IncludeLower) {
+				return true // the current bound is stricter
+			}
+		}
+
+		r.Lower, r.IncludeLower = v, op == GTE
+
+	case LT, LTE:
+		if r.Upper != nil {
+			c, ok := compareValues(v, r.Upper)
+			if !ok {
+				return false
+			}
+			if c > 0 || (c == 0 && !r.IncludeUpper) {
+				return true
+			}
+		}
+
+		r.Upper, r.IncludeUpper = v, op == LTE
+	}
+
+	return true
+}
+
+/*
+ * Return true if no value can match the range (i.e. x > 5 AND x < 2)
+ */
+func (r Range) empty() bool {
+	if r.Lower == nil || r.Upper == nil {
+		return false
+	}
+
+	c, _ := compareValues(r.Lower, r.Upper)
+	return c > 0 || (c == 0 && !(r.IncludeLower && r.IncludeUpper))
+}
+
+/*
+ * Return the node for a range (a single comparison if there is only one bound)
+ */
+func rangeNode(field string, r Range) Node {
+	switch {
+	case r.Upper == nil:
+		return &Predicate{Field: field, Op: r.lowerOperator(), Value: r.Lower}
+	case r.Lower == nil:
+		return &Predicate{Field: field, Op: r.upperOperator(), Value: r.Upper}
+	}
+
+	return &Predicate{Field: field, Op: RANGE_EXPR, Value: r}
+}
+
+func isComparison(n Node) (*Predicate, bool) {
+	p, ok := n.(*Predicate)
+	if !ok {
+		return nil, false
+	}
+
+	switch p.Op {
+	case LT, LTE, GT, GTE, RANGE_EXPR:
+		if _, raw := p.Value.(Raw); !raw {
+			return p, true
+		}
+	}
+
+	return nil, false
+}
+
+func mergeRanges(n Node) Node {
+	b, ok := n.(*Boolean)
+	if !ok || b.Op != OP_AND {
+		return nil
+	}
+
+	count := map[string]int{}
+	for _, op := range b.Operands {
+		if p, ok := isComparison(op); ok {
+			count[p.Field]++
+		}
+	}
+
+	ranges := map[string]*Range{}
+	merged := map[string]bool{}
+
+	for field, c := range count {
+		if c < 2 {
+			continue
+		}
+
+		r := &Range{}
+		ok := true
+
+		for _, op := range b.Operands {
+			if p, _ := isComparison(op); p != nil && p.Field == field {
+				if p.Op == RANGE_EXPR {
+					pr := p.Value.(Range)
+					ok = r.add(pr.lowerOperator(), pr.Lower) && r.add(pr.upperOperator(), pr.Upper)
+				} else {
+					ok = r.add(p.Op, p.Value)
+				}
+
+				if !ok {
+					break
+				}
+			}
+		}
+
+		if ok && !r.empty() {
+			ranges[field] = r
+		}
+	}
+
+	if len(ranges) == 0 {
+		return nil
+	}
+
+	nb := &Boolean{Op: OP_AND}
+
+	for _, op := range b.Operands {
+		p, _ := isComparison(op)
+		if p == nil || ranges[p.Field] == nil {
+			nb.Operands = append(nb.Operands, op)
+		} else if !merged[p.Field] {
+			merged[p.Field] = true
+			nb.Operands = append(nb.Operands, rangeNode(p.Field, *ranges[p.Field]))
+		}
+	}
+
+	if len(nb.Operands) == 1 {
+		return nb.Operands[0]
+	}
+
+	return nb
+}
+
+/*
+ * a = v or a IN (...), but not a = '' (that matches any value)
+ */
+func isEquality(n Node) (*Predicate, bool) {
+	p, ok := n.(*Predicate)
+	if !ok {
+		return nil, false
+	}
+
+	switch p.Op {
+	case EQ:
+		if s, ok := p.Value.(string); ok && s == "" {
+			return nil, false
+		}
+		return p, true
+
+	case IN:
+		return p, true
+	}
+
+	return nil, false
+}
+
+func mergeEqualities(n Node) Node {
+	b, ok := n.(*Boolean)
+	if !ok || b.Op != OP_OR {
+		return nil
+	}
+
+	count := map[string]int{}
+	for _, op := range b.Operands {
+		if p, ok := isEquality(op); ok {
+			count[p.Field]++
+		}
+	}
+
+	values := map[string][]interface{}{}
+	seen := map[string]map[string]bool{}
+
+	for _, op := range b.Operands {
+		p, _ := isEquality(op)
+		if p == nil || count[p.Field] < 2 {
+			continue
+		}
+
+		list := []interface{}{p.Value}
+		if p.Op == IN {
+			list, _ = p.Value.([]interface{})
+		}
+
+		if seen[p.Field] == nil {
+			seen[p.Field] = map[string]bool{}
+		}
+
+		for _, v := range list {
+			if k := fmt.Sprintf("%T:%v", v, v); !seen[p.Field][k] {
+				seen[p.Field][k] = true
+				values[p.Field] = append(values[p.Field], v)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	nb := &Boolean{Op: OP_OR}
+	merged := map[string]bool{}
+
+	for _, op := range b.Operands {
+		p, _ := isEquality(op)
+		if p == nil || values[p.Field] == nil {
+			nb.Operands = append(nb.Operands, op)
+		} else if !merged[p.Field] {
+			merged[p.Field] = true
+			nb.Operands = append(nb.Operands, &Predicate{Field: p.Field, Op: IN, Value: values[p.Field]})
+		}
+	}
+
+	if len(nb.Operands) == 1 {
+		return nb.Operands[0]
+	}
+
+	return nb
+}
+
+//
+// Filter context
+//
+
+/*
+ * Return true if the expression contributes to the score (full text matches)
+ */
+func (e *Expression) scoring() bool {
+	switch e.op {
+	case STRING_EXPR:
+		return true
+
+	case EQ:
+		_, s := e.operands[0].(NameValue).Value.(string)
+		return s
+
+	case IN:
+		values, _ := e.operands[0].(NameValue).Value.([]interface{})
+		for _, v := range values {
+			if _, s := v.(string); s {
+				return true
+			}
+		}
+
+	case OP_AND, OP_OR:
+		for _, op := range e.operands {
+			if op.(*Expression).scoring() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func queryStringQuery(e *Expression) jmap {
+	return jmap{"query_string": jmap{"query": e.QueryString()}}
+}
+
+/*
+ * Return the query for a non-scoring expression
+ */
+func filterQuery(e *Expression) jmap {
+	switch e.op {
+	case EXISTS_EXPR:
+		return jmap{"exists": jmap{"field": e.operands[0].(string)}}
+
+	case EQ:
+		if nv := e.operands[0].(NameValue); !isRaw(nv.Value) {
+			return jmap{"term": jmap{nv.Name: nv.Value}}
+		}
+
+	case IN:
+		if nv := e.operands[0].(NameValue); !isRaw(nv.Value) {
+			return jmap{"terms": jmap{nv.Name: nv.Value}}
+		}
+
+	case LT, LTE, GT, GTE:
+		if nv := e.operands[0].(NameValue); !isRaw(nv.Value) {
+			return jmap{"range": jmap{nv.Name: jmap{rangeKeys[e.op]: nv.Value}}}
+		}
+
+	case RANGE_EXPR:
+		nv := e.operands[0].(NameValue)
+		r := nv.Value.(Range)
+
+		if !isRaw(r.Lower) && !isRaw(r.Upper) {
+			return jmap{"range": jmap{nv.Name: jmap{
+				rangeKeys[r.lowerOperator()]: r.Lower,
+				rangeKeys[r.upperOperator()]: r.Upper,
+			}}}
+		}
+	}
+
+	return queryStringQuery(e)
+}
+
+var rangeKeys = map[Operator]string{
+	LT:  "lt",
+	LTE: "lte",
+	GT:  "gt",
+	GTE: "gte",
+}
+
+func isRaw(v interface{}) bool {
+	switch vv := v.(type) {
+	case Raw:
+		return true
+
+	case []interface{}:
+		for _, item := range vv {
+			if isRaw(item) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+/*
+ * Return the query for a WHERE expression. With filterContext the non-scoring operands of a top level AND
+ * are moved to the filter context of a bool query.
+ */
+func whereQuery(e *Expression, filterContext bool) jmap {
+	if !filterContext {
+		return queryStringQuery(e)
+	}
+
+	operands := []interface{}{e}
+	if e.op == OP_AND {
+		operands = e.operands
+	}
+
+	var must *Expression
+	var filter jarr
+
+	for _, op := range operands {
+		if expr := op.(*Expression); expr.scoring() {
+			must = must.And(expr)
+		} else {
+			filter = append(filter, filterQuery(expr))
+		}
+	}
+
+	if len(filter) == 0 {
+		return queryStringQuery(e)
+	}
+
+	bq := jmap{"filter": filter}
+	if must != nil {
+		bq["must"] = queryStringQuery(must)
+	}
+
+	return jmap{"bool": bq}
+}
@@ -0,0 +1,110 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptimize(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected string
+		rules    []string
+	}{
+		{"x > 1 AND x < 5", "x:{1 TO 5}", []string{"merge ranges"}},
+		{"x > 1 AND y = 'a' AND x >= 3 AND x <= 10", `x:[3 TO 10] AND y:"a"`, []string{"merge ranges"}},
+		{"x > 'now-1d' AND x > 'now-2d'", `x:{"now-1d" TO *} AND x:{"now-2d" TO *}`, nil},
+		{"n > '9' AND n > '10'", `n:{"9" TO *} AND n:{"10" TO *}`, nil},
+		{"a > 5 AND a < 2", "a:{5 TO *} AND a:{* TO 2}", nil},
+		{"a >= 2 AND a <= 2.0", "a:[2 TO 2]", []string{"merge ranges"}},
+		{"a = 1 OR a = 2 OR b = 3 OR a IN (2, 4)", "a:(1 OR 2 OR 4) OR b:3", []string{"terms"}},
+		{"NOT (a = 1 OR NOT b != 2)", "NOT a:1 AND NOT b:2", []string{"not", "de morgan", "not", "not"}},
+		{"NOT (a = 1 AND b = 2)", "NOT (a:1 AND b:2)", nil},
+		{"a = 1 AND (b = 2 AND (c = 3 AND d = 4))", "a:1 AND b:2 AND c:3 AND d:4", []string{"flatten", "flatten"}},
+	}
+
+	for _, test := range tests {
+		parser := NewParser("SELECT * FROM idx WHERE " + test.where)
+		if err := parser.Parse(); err != nil {
+			t.Fatal(err)
+		}
+
+		q := parser.Query()
+		steps := q.Optimize()
+
+		var rules []string
+		for _, s := range steps {
+			rules = append(rules, s.Rule)
+		}
+
+		if got := q.WhereExpr.QueryString(); got != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.where, test.expected, got)
+		}
+		if !reflect.DeepEqual(rules, test.rules) {
+			t.Errorf("%v: expected rules %q, got %q (%v)", test.where, test.rules, rules, steps)
+		}
+	}
+}
+
+func TestOptimizeQuery(t *testing.T) {
+	jq, _, _, err := ParseQuery("SELECT * FROM idx WHERE title = 'go' AND n > 1 AND n <= 5 AND tag IN (1, 2)", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := jmap{
+		"bool": jmap{
+			"must": jmap{"query_string": jmap{"query": `title:"go"`}},
+			"filter": jarr{
+				jmap{"range": jmap{"n": jmap{"gt": 1, "lte": 5}}},
+				jmap{"terms": jmap{"tag": []interface{}{1, 2}}},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(jq["query"], expected) {
+		t.Errorf("expected %v, got %v", expected, jq["query"])
+	}
+
+	jq, _, _, err = ParseQuery("SELECT * FROM idx WHERE title = 'go' AND n > 1 AND n <= 5", "", WithoutOptimizer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = jmap{"query_string": jmap{"query": `title:"go" AND n:{1 TO *} AND n:[* TO 5]`}}
+	if !reflect.DeepEqual(jq["query"], expected) {
+		t.Errorf("expected %v, got %v", expected, jq["query"])
+	}
+}
+
+func TestOptimizeDeMorgan(t *testing.T) {
+	tests := []struct {
+		where    string
+		expected jmap
+	}{
+		{
+			"NOT (a = 1 OR b = 2)",
+			jmap{"bool": jmap{"filter": jarr{
+				jmap{"query_string": jmap{"query": "NOT a:1"}},
+				jmap{"query_string": jmap{"query": "NOT b:2"}},
+			}}},
+		},
+		{
+			"NOT (a = 1 AND b = 2)",
+			jmap{"bool": jmap{"filter": jarr{
+				jmap{"query_string": jmap{"query": "NOT (a:1 AND b:2)"}},
+			}}},
+		},
+	}
+
+	for _, test := range tests {
+		jq, _, _, err := ParseQuery("SELECT * FROM idx WHERE "+test.where, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(jq["query"], test.expected) {
+			t.Errorf("%v: expected %v, got %v", test.where, test.expected, jq["query"])
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package elseql
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+/*
+ * A parameter placeholder in a query (?, $n or :name).
+ * Placeholders can be used where a value is expected and are replaced with the bound values after parsing.
+ */
+type Param struct {
+	Name  string // :name
+	Index int    // ? or $n (1-based)
+}
+
+func (p Param) String() string {
+	if p.Name != "" {
+		return ":" + p.Name
+	}
+
+	return "$" + strconv.Itoa(p.Index)
+}
+
+/*
+ * Bind positional parameters (? and $n). Should be called before Parse.
+ */
+func (p *ElseParser) Bind(args ...interface{}) *ElseParser {
+	p.args = args
+	return p
+}
+
+/*
+ * Bind named parameters (:name). Should be called before Parse.
+ */
+func (p *ElseParser) BindNamed(params map[string]interface{}) *ElseParser {
+	p.params = params
+	return p
+}
+
+/*
+ * Options for ParseQuery and Search
+ */
+type QueryOption func(*queryOptions)
+
+type queryOptions struct {
+	args        []interface{}
+	params      map[string]interface{}
+	noOptimizer bool
+	validate    bool
+	refresh     string
+	dryRun      bool
+	dropPattern bool
+	progress    func(map[string]interface{})
+	taskTimeout *time.Duration
+
+	timestampField string
+
+	keywordFields bool
+	mapping       Mapping
+}
+
+func getQueryOptions(options []QueryOption) *queryOptions {
+	opts := &queryOptions{}
+
+	for _, o := range options {
+		o(opts)
+	}
+
+	return opts
+}
+
+/*
+ * Bind positional parameters (? and $n)
+ */
+func WithArgs(args ...interface{}) QueryOption {
+	return func(o *queryOptions) {
+		o.args = args
+	}
+}
+
+/*
+ * Bind named parameters (:name)
+ */
+func WithParams(params map[string]interface{}) QueryOption {
+	return func(o *queryOptions) {
+		o.params = params
+	}
+}
+
+/*
+ * Convert a bound value to one of the types returned by the parser (string, int, float64, bool, Raw, DateMath)
+ * or to a list of values (only valid with IN)
+ */
+func bindValue(v interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case time.Time:
+		return vv.Format(time.RFC3339Nano), nil
+
+	case Raw: // explicit opt-in for raw Lucene fragments
+		return vv, nil
+
+	case DateMath:
+		return vv, nil
+	}
+
+	rv := reflect.ValueOf(v)
+
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+
+	case reflect.Bool:
+		return rv.Bool(), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(rv.Int()), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if rv.Uint() > math.MaxInt {
+			return nil, fmt.Errorf("parameter value %v out of range", v)
+		}
+
+		return int(rv.Uint()), nil
+
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+
+	case reflect.Slice, reflect.Array:
+		list := make([]interface{}, 0, rv.Len())
+
+		for i := 0; i < rv.Len(); i++ {
+			item, err := bindValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := item.([]interface{}); ok {
+				return nil, fmt.Errorf("invalid nested list parameter %v", v)
+			}
+
+			list = append(list, item)
+		}
+
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("invalid parameter type %T", v)
+}
+
+/*
+ * Return a function that returns the value bound to a parameter (args are positional and params named parameters)
+ */
+func paramBinder(args []interface{}, params map[string]interface{}) func(Param) (interface{}, error) {
+	return func(param Param) (interface{}, error) {
+		return paramValue(args, params, param)
+	}
+}
+
+/*
+ * Return the value bound to a parameter
+ */
+func paramValue(args []interface{}, params map[string]interface{}, param Param) (interface{}, error) {
+	var v interface{}
+	var ok bool
+
+	if param.Name != "" {
+		v, ok = params[param.Name]
+	} else if param.Index <= len(args) {
+		v, ok = args[param.Index-1], true
+	}
+
+	if !ok {
+		return nil, ParseError{Msg: "missing value for parameter " + param.String()}
+	}
+
+	v, err := bindValue(v)
+	if err != nil {
+		return nil, ParseError{Msg: err.Error() + " for parameter " + param.String()}
+	}
+
+	return v, nil
+}
+
+/*
+ * Replace the parameter placeholders in WHERE and FILTER with the bound values.
+ * If no values are bound the placeholders are left in the query (they can't be translated to a search request).
+ */
+func (p *ElseParser) bindParams() error {
+	if p.args == nil && p.params == nil {
+		return nil
+	}
+
+	return p.query.bindParams(paramBinder(p.args, p.params))
+}
+
+/*
+ * Return a copy of the query with the parameter placeholders replaced with the values passed with
+ * WithArgs and WithParams (the query is returned as is if there are no values)
+ */
+func (q *Query) bindOptions(opts *queryOptions) (*Query, error) {
+	if opts.args == nil && opts.params == nil {
+		return q, nil
+	}
+
+	bound := *q
+	bound.WhereExpr = NewExpression(q.WhereExpr.AST())
+	bound.FilterExpr = NewExpression(q.FilterExpr.AST())
+	bound.SetList = append([]NameValue(nil), q.SetList...)
+
+	if q.TimeRange != nil {
+		tr := *q.TimeRange
+		bound.TimeRange = &tr
+	}
+
+	if q.Values != nil {
+		bound.Values = make([][]interface{}, 0, len(q.Values))
+		for _, row := range q.Values {
+			bound.Values = append(bound.Values, append([]interface{}(nil), row...))
+		}
+	}
+
+	if err := bound.bindParams(paramBinder(opts.args, opts.params)); err != nil {
+		return nil, err
+	}
+
+	return &bound, nil
+}
+
+/*
+ * Return an error if the query contains parameter placeholders
+ */
+func (q *Query) checkParams() error {
+	unbound := func(param Param) (interface{}, error) {
+		return nil, ParseError{Msg: "missing value for parameter " + param.String()}
+	}
+
+	return q.bindParams(unbound)
+}
+
+/*
+ * Replace the parameter placeholders in the expressions, the time range and the INSERT and UPDATE values with the result of bind
+ */
+func (q *Query) bindParams(bind func(Param) (interface{}, error)) error {
+	if err := q.WhereExpr.bindParams(bind); err != nil {
+		return err
+	}
+	if err := q.FilterExpr.bindParams(bind); err != nil {
+		return err
+	}
+	if err := q.TimeRange.bindParams(bind); err != nil {
+		return err
+	}
+
+	for i, nv := range q.SetList {
+		value, err := bindSetValue(nv.Value, bind)
+		if err != nil {
+			return err
+		}
+
+		q.SetList[i].Value = value
+	}
+
+	for _, row := range q.Values {
+		for i, v := range row {
+			param, ok := v.(Param)
+			if !ok {
+				continue
+			}
+
+			value, err := bind(param)
+			if err != nil {
+				return err
+			}
+			if _, ok := value.([]interface{}); ok {
+				return ParseError{Msg: "invalid list value for parameter " + param.String()}
+			}
+
+			row[i] = value
+		}
+	}
+
+	return nil
+}
+
+func (e *Expression) bindParams(bind func(Param) (interface{}, error)) error {
+	if e == nil {
+		return nil
+	}
+
+	for i, op := range e.operands {
+		switch v := op.(type) {
+		case *Expression:
+			if err := v.bindParams(bind); err != nil {
+				return err
+			}
+
+		case NameValue:
+			switch vv := v.Value.(type) {
+			case Param:
+				value, err := bind(vv)
+				if err != nil {
+					return err
+				}
+
+				if _, ok := value.([]interface{}); ok {
+					return ParseError{Msg: "list value for parameter " + vv.String() + " can only be used with IN"}
+				}
+
+				e.operands[i] = NameValue{v.Name, value}
+
+			case []interface{}:
+				values := make([]interface{}, 0, len(vv))
+
+				for _, item := range vv {
+					if param, ok := item.(Param); ok {
+						value, err := bind(param)
+						if err != nil {
+							return err
+						}
+
+						if list, ok := value.([]interface{}); ok {
+							values = append(values, list...)
+						} else {
+							values = append(values, value)
+						}
+					} else {
+						values = append(values, item)
+					}
+				}
+
+				if len(values) == 0 {
+					return ParseError{Msg: "empty IN list for " + v.Name}
+				}
+
+				e.operands[i] = NameValue{v.Name, values}
+			}
+		}
+	}
+
+	return nil
+}
@@ -3,12 +3,33 @@ package elseql
 import (
 	"fmt"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 	"text/scanner"
+	"unicode"
 )
 
-/* SELECT a,b,c FACETS d,e,f FROM t WHERE expr FILTER expr ORDER BY g,h,i LIMIT n,m */
+/*
+ * [EXPLAIN [ANALYZE]] SELECT [DISTINCT] [FIELDS|DOCVALUES|STORED] a,b,c FACETS d,e,f FROM t [JOIN u ON t.x = u.y] WHERE expr FILTER expr
+ *   HIGHLIGHT j,k (options) ORDER BY g,h,i LIMIT n,m
+ *
+ * SELECT a,b,c INTO index [SET d = a + b] FROM t WHERE expr LIMIT n
+ *
+ * WHERE expr can be followed by DURING LAST 15m or SINCE value UNTIL value (see timerange.go)
+ *
+ * SHOW INDICES [LIKE 'pattern'] | SHOW ALIASES | SHOW STATS index | DESCRIBE index
+ *
+ * INSERT INTO index (a,b,c) VALUES (1,2,3), (4,5,6) | INSERT INTO index [(a,b,c)] SELECT ...
+ *
+ * UPDATE index SET a = 1, b = b + 1 WHERE expr LIMIT n | DELETE FROM index WHERE expr LIMIT n
+ *
+ * CREATE INDEX index (a KEYWORD, b DATE, c TEXT) WITH (shards=1, replicas=0) | DROP INDEX index | ALTER INDEX index ADD d LONG
+ *
+ * CREATE ALIAS name FOR index WHERE expr | SWAP ALIAS name FROM index TO index | DROP ALIAS name | ROLLOVER name
+ *
+ * Comments can be -- line comments or C style block comments (see also ParseScript for multiple statements).
+ */
 
 var (
 	Debug = false
@@ -40,6 +61,43 @@ const (
 	OR
 	NOT
 	BETWEEN
+	JOIN
+	ON
+	DISTINCT
+	HIGHLIGHT
+	FIELDS
+	DOCVALUES
+	STORED
+	RAW
+	EXPLAIN
+	ANALYZE
+	SHOW
+	DESCRIBE
+	INDICES
+	ALIASES
+	STATS
+	LIKE
+	INSERT
+	INTO
+	VALUES
+	UPDATE
+	SET
+	DELETE
+	CREATE
+	DROP
+	ALTER
+	INDEX
+	ADD
+	WITH
+	ALIAS
+	FOR
+	SWAP
+	TO
+	ROLLOVER
+	DURING
+	LAST
+	SINCE
+	UNTIL
 
 	NO_KEYWORD Keyword = -1
 
@@ -61,6 +119,7 @@ const (
 	STRING_EXPR
 	EXISTS_EXPR
 	MISSING_EXPR
+	RANGE_EXPR // merged comparisons on the same field (see Optimize)
 
 	NO_OPERATOR Operator = -1
 )
@@ -80,6 +139,33 @@ func FindKeyword(ks string) (k Keyword, ok bool) {
 	return
 }
 
+/*
+ * Return true if the word is a reserved keyword (that cannot be used as an identifier)
+ */
+func isReserved(word string) bool {
+	k, ok := FindKeyword(word)
+	return ok && !contextKeywords[k]
+}
+
+/*
+ * Return true if name can be parsed as an identifier (id.id...)
+ */
+func isIdentifier(name string) bool {
+	for i, part := range strings.Split(name, string(id_sep)) {
+		if part == "" || (i == 0 && isReserved(part)) {
+			return false
+		}
+
+		for j, c := range part {
+			if !(c == '_' || unicode.IsLetter(c) || (j > 0 && unicode.IsDigit(c))) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 type Operator int
 
 func (op Operator) String() string {
@@ -88,45 +174,160 @@ func (op Operator) String() string {
 
 var (
 	stringToKeyword = map[string]Keyword{
-		"SELECT":  SELECT,
-		"FACETS":  FACETS,
-		"SCRIPT":  SCRIPT,
-		"FROM":    FROM,
-		"WHERE":   WHERE,
-		"FILTER":  FILTER,
-		"EXIST":   EXIST,
-		"MISSING": MISSING,
-		"ORDER":   ORDER,
-		"BY":      BY,
-		"LIMIT":   LIMIT,
-		"AFTER":   AFTER,
-		"ASC":     ASC,
-		"DESC":    DESC,
-		"AND":     AND,
-		"OR":      OR,
-		"NOT":     NOT,
-		"BETWEEN": BETWEEN,
+		"SELECT":    SELECT,
+		"FACETS":    FACETS,
+		"SCRIPT":    SCRIPT,
+		"FROM":      FROM,
+		"WHERE":     WHERE,
+		"FILTER":    FILTER,
+		"EXIST":     EXIST,
+		"MISSING":   MISSING,
+		"ORDER":     ORDER,
+		"BY":        BY,
+		"LIMIT":     LIMIT,
+		"AFTER":     AFTER,
+		"ASC":       ASC,
+		"DESC":      DESC,
+		"AND":       AND,
+		"OR":        OR,
+		"NOT":       NOT,
+		"BETWEEN":   BETWEEN,
+		"JOIN":      JOIN,
+		"ON":        ON,
+		"DISTINCT":  DISTINCT,
+		"HIGHLIGHT": HIGHLIGHT,
+		"FIELDS":    FIELDS,
+		"DOCVALUES": DOCVALUES,
+		"STORED":    STORED,
+		"RAW":       RAW,
+		"EXPLAIN":   EXPLAIN,
+		"ANALYZE":   ANALYZE,
+		"SHOW":      SHOW,
+		"DESCRIBE":  DESCRIBE,
+		"INDICES":   INDICES,
+		"ALIASES":   ALIASES,
+		"STATS":     STATS,
+		"LIKE":      LIKE,
+		"INSERT":    INSERT,
+		"INTO":      INTO,
+		"VALUES":    VALUES,
+		"UPDATE":    UPDATE,
+		"SET":       SET,
+		"DELETE":    DELETE,
+		"CREATE":    CREATE,
+		"DROP":      DROP,
+		"ALTER":     ALTER,
+		"INDEX":     INDEX,
+		"ADD":       ADD,
+		"WITH":      WITH,
+		"ALIAS":     ALIAS,
+		"FOR":       FOR,
+		"SWAP":      SWAP,
+		"TO":        TO,
+		"ROLLOVER":  ROLLOVER,
+		"DURING":    DURING,
+		"LAST":      LAST,
+		"SINCE":     SINCE,
+		"UNTIL":     UNTIL,
 	}
 
 	keywordToString = map[Keyword]string{
-		SELECT:  "SELECT",
-		FACETS:  "FACETS",
-		SCRIPT:  "SCRIPT",
-		FROM:    "FROM",
-		WHERE:   "WHERE",
-		FILTER:  "FILTER",
-		EXIST:   "EXIST",
-		MISSING: "MISSING",
-		ORDER:   "ORDER",
-		BY:      "BY",
-		LIMIT:   "LIMIT",
-		AFTER:   "AFTER",
-		ASC:     "ASC",
-		DESC:    "DESC",
-		AND:     "AND",
-		OR:      "OR",
-		NOT:     "NOT",
-		BETWEEN: "BETWEEN",
+		SELECT:    "SELECT",
+		FACETS:    "FACETS",
+		SCRIPT:    "SCRIPT",
+		FROM:      "FROM",
+		WHERE:     "WHERE",
+		FILTER:    "FILTER",
+		EXIST:     "EXIST",
+		MISSING:   "MISSING",
+		ORDER:     "ORDER",
+		BY:        "BY",
+		LIMIT:     "LIMIT",
+		AFTER:     "AFTER",
+		ASC:       "ASC",
+		DESC:      "DESC",
+		AND:       "AND",
+		OR:        "OR",
+		NOT:       "NOT",
+		BETWEEN:   "BETWEEN",
+		JOIN:      "JOIN",
+		ON:        "ON",
+		DISTINCT:  "DISTINCT",
+		HIGHLIGHT: "HIGHLIGHT",
+		FIELDS:    "FIELDS",
+		DOCVALUES: "DOCVALUES",
+		STORED:    "STORED",
+		RAW:       "RAW",
+		EXPLAIN:   "EXPLAIN",
+		ANALYZE:   "ANALYZE",
+		SHOW:      "SHOW",
+		DESCRIBE:  "DESCRIBE",
+		INDICES:   "INDICES",
+		ALIASES:   "ALIASES",
+		STATS:     "STATS",
+		LIKE:      "LIKE",
+		INSERT:    "INSERT",
+		INTO:      "INTO",
+		VALUES:    "VALUES",
+		UPDATE:    "UPDATE",
+		SET:       "SET",
+		DELETE:    "DELETE",
+		CREATE:    "CREATE",
+		DROP:      "DROP",
+		ALTER:     "ALTER",
+		INDEX:     "INDEX",
+		ADD:       "ADD",
+		WITH:      "WITH",
+		ALIAS:     "ALIAS",
+		FOR:       "FOR",
+		SWAP:      "SWAP",
+		TO:        "TO",
+		ROLLOVER:  "ROLLOVER",
+		DURING:    "DURING",
+		LAST:      "LAST",
+		SINCE:     "SINCE",
+		UNTIL:     "UNTIL",
+	}
+
+	// keywords that are only recognized in their position in a statement (they can also be used as identifiers)
+	contextKeywords = map[Keyword]bool{
+		JOIN:      true,
+		ON:        true,
+		DISTINCT:  true,
+		HIGHLIGHT: true,
+		EXPLAIN:   true,
+		ANALYZE:   true,
+		FIELDS:    true,
+		DOCVALUES: true,
+		STORED:    true,
+		RAW:       true,
+		SHOW:      true,
+		DESCRIBE:  true,
+		INDICES:   true,
+		ALIASES:   true,
+		STATS:     true,
+		LIKE:      true,
+		INSERT:    true,
+		INTO:      true,
+		VALUES:    true,
+		SET:       true,
+		UPDATE:    true,
+		DELETE:    true,
+		CREATE:    true,
+		DROP:      true,
+		ALTER:     true,
+		INDEX:     true,
+		ADD:       true,
+		WITH:      true,
+		ALIAS:     true,
+		FOR:       true,
+		TO:        true,
+		SWAP:      true,
+		ROLLOVER:  true,
+		DURING:    true,
+		LAST:      true,
+		SINCE:     true,
+		UNTIL:     true,
 	}
 
 	opToString = map[Operator]string{
@@ -143,6 +344,7 @@ var (
 		STRING_EXPR:  "\"\"",
 		EXISTS_EXPR:  "EXIST",
 		MISSING_EXPR: "MISSING",
+		RANGE_EXPR:   "RANGE",
 		OPENP:        "(",
 		CLOSEP:       ")",
 	}
@@ -165,28 +367,15 @@ func (nv NameValue) String() string {
 }
 
 func (nv NameValue) QueryString() string {
-	if s, ok := nv.Value.(string); ok {
-		if s == "" {
-			return fmt.Sprintf("%v:*", nv.Name)
-		}
-		if strings.ContainsAny(s[0:1], "([{") || strings.Contains(s, "*") {
-			return fmt.Sprintf("%v:%v", nv.Name, s)
-		}
-		return fmt.Sprintf("%v:%q", nv.Name, s)
-	} else {
-		return fmt.Sprintf("%v:%v", nv.Name, stringify(nv.Value, "null"))
+	if s, ok := nv.Value.(string); ok && s == "" {
+		return nv.Name + ":*"
 	}
+
+	return nv.Name + ":" + queryValue(nv.Value)
 }
 
 func (nv NameValue) Strings() (n, v string) {
-	n = nv.Name
-	if s, ok := nv.Value.(string); ok {
-		v = fmt.Sprintf("%q", s)
-	} else {
-		v = fmt.Sprintf("%v", nv.Value)
-	}
-
-	return
+	return nv.Name, queryValue(nv.Value)
 }
 
 func (nv NameValue) List(sep string) (n, v string) {
@@ -196,13 +385,7 @@ func (nv NameValue) List(sep string) (n, v string) {
 		vv := make([]string, 0, len(a))
 
 		for _, item := range a {
-			if s, ok := item.(string); ok {
-				v = fmt.Sprintf("%q", s)
-			} else {
-				v = fmt.Sprintf("%v", item)
-			}
-
-			vv = append(vv, v)
+			vv = append(vv, queryValue(item))
 		}
 
 		v = strings.Join(vv, sep)
@@ -215,41 +398,113 @@ func (nv NameValue) List(sep string) (n, v string) {
  * This is the output of a parsed statement
  */
 type Query struct {
+	Command Keyword // SELECT (the default) or a command: SHOW, DESCRIBE, INSERT, UPDATE, DELETE, CREATE, DROP, ALTER, SWAP, ROLLOVER (see commands.go)
+	Show    Keyword // SHOW INDICES, ALIASES or STATS
+	Pattern string  // SHOW INDICES LIKE 'pattern'
+
+	Target  string          // INSERT INTO target, SELECT ... INTO target
+	Columns []string        // INSERT INTO target (columns)
+	Values  [][]interface{} // INSERT INTO target VALUES (rows), nil for INSERT INTO target SELECT
+
+	SetList []NameValue // UPDATE index SET name = value (values can be a FieldRef or a SetExpression)
+
+	Object    Keyword           // CREATE, DROP or ALTER object (INDEX or ALIAS)
+	Name      string            // CREATE, DROP, SWAP ALIAS name, ROLLOVER name
+	FieldList []FieldDefinition // CREATE INDEX index (fields), ALTER INDEX index ADD fields
+	Settings  []NameValue       // CREATE INDEX index WITH (settings), ROLLOVER name WITH (conditions)
+
+	Explain bool // EXPLAIN: return the translation without executing the query
+	Analyze bool // EXPLAIN ANALYZE: execute the query with profiling
+
+	Distinct   bool
+	Retrieve   Keyword // FIELDS, DOCVALUES or STORED to retrieve the select list without _source
 	SelectList []string
 	FacetList  []string
 
+	FieldOptions map[string][]NameValue // per-field options (i.e. format) for FIELDS and DOCVALUES
+	FacetFields  map[string]string      // field of a facet aggregation, if not the facet name (see UseKeywordFields)
+
 	Index      string
+	Alias      string
+	Join       *Join
 	WhereExpr  *Expression
 	FilterExpr *Expression
+	TimeRange  *TimeRange // DURING LAST duration, SINCE value UNTIL value
 
 	Script    *NameValue
 	OrderList []NameValue
 
+	HighlightList    []string
+	HighlightOptions []NameValue
+
 	From  int
 	Size  int
 	After string
 }
 
 func (q *Query) String() string {
-	return fmt.Sprintf(`Select %v
+	return fmt.Sprintf(`Command %v %v %v
+    Insert %v %v %v
+    Set %v
+    Object %v %v %v %v
+    Explain %v %v
+    Distinct %v
+    Retrieve %v
+    Select %v
+    Options %v
     Facet %v
     Index %v
+    Alias %v
+    Join %v
     Where %v
+    Time %v
     Filter %v
     Script %v
+    Highlight %v %v
     Order %v
     From %v
     Size %v
-    After %v`, q.SelectList,
+    After %v`, q.Command, q.Show, q.Pattern,
+		q.Target, q.Columns, q.Values,
+		q.SetList,
+		q.Object, q.Name, q.FieldList, q.Settings,
+		q.Explain, q.Analyze,
+		q.Distinct,
+		q.Retrieve,
+		q.SelectList,
+		q.FieldOptions,
 		q.FacetList,
 		q.Index,
+		q.Alias,
+		q.Join,
 		q.WhereExpr.QueryString(),
+		formatTimeRange(q.TimeRange),
 		q.FilterExpr.QueryString(),
 		q.Script,
+		q.HighlightList, q.HighlightOptions,
 		q.OrderList,
 		q.From, q.Size, q.After)
 }
 
+/*
+ * A client-side join between the FROM index and a second index.
+ * Left and Right are the join fields (without alias) in the FROM index and in the joined index.
+ */
+type Join struct {
+	Index string
+	Alias string
+	Left  string
+	Right string
+}
+
+func (j *Join) String() string {
+	if j == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("%v %v ON %v = %v", j.Index, j.Alias, j.Left, j.Right)
+}
+
 type Expression struct {
 	op       Operator
 	operands []interface{}
@@ -281,7 +536,7 @@ func (e *Expression) QueryString() string {
 
 	case OP_NOT:
 		expr := e.operands[0].(*Expression)
-		return "NOT " + expr.QueryString()
+		return "NOT " + expr.groupString()
 
 	case EQ:
 		nv := e.operands[0].(NameValue)
@@ -310,6 +565,10 @@ func (e *Expression) QueryString() string {
 	case OP_AND, OP_OR:
 		return e.join()
 
+	case RANGE_EXPR:
+		nv := e.operands[0].(NameValue)
+		return nv.Name + ":" + nv.Value.(Range).QueryString()
+
 	case IN:
 		// this should be {"terms": {"name": [values]}}
 		n, v := e.operands[0].(NameValue).List(" OR ")
@@ -331,16 +590,27 @@ func (e *Expression) join() string {
 	sep := e.op.String()
 	expr := e.operands[0].(*Expression)
 
-	ret := expr.QueryString()
+	ret := expr.groupString()
 
 	for _, op := range e.operands[1:] {
 		expr = op.(*Expression)
-		ret += fmt.Sprintf(" %v %v", sep, expr.QueryString())
+		ret += fmt.Sprintf(" %v %v", sep, expr.groupString())
 	}
 
 	return ret
 }
 
+/*
+ * Return the query string for a nested expression (boolean expressions are enclosed in parentheses)
+ */
+func (e *Expression) groupString() string {
+	if e.op == OP_AND || e.op == OP_OR {
+		return "(" + e.QueryString() + ")"
+	}
+
+	return e.QueryString()
+}
+
 func (e *Expression) addOperand(expr interface{}) *Expression {
 	e.operands = append(e.operands, expr)
 	return e
@@ -350,6 +620,44 @@ func (e *Expression) GetOperand() interface{} {
 	return e.operands[0]
 }
 
+/*
+ * Replace all the field names referenced in the expression with the result of fn
+ */
+func (e *Expression) renameFields(fn func(string) (string, error)) error {
+	if e == nil {
+		return nil
+	}
+
+	for i, op := range e.operands {
+		switch v := op.(type) {
+		case *Expression:
+			if err := v.renameFields(fn); err != nil {
+				return err
+			}
+
+		case NameValue:
+			name, err := fn(v.Name)
+			if err != nil {
+				return err
+			}
+
+			e.operands[i] = NameValue{name, v.Value}
+
+		case string:
+			if e.op == EXISTS_EXPR || e.op == MISSING_EXPR {
+				name, err := fn(v)
+				if err != nil {
+					return err
+				}
+
+				e.operands[i] = name
+			}
+		}
+	}
+
+	return nil
+}
+
 func singleOperand(op Operator, expr interface{}) *Expression {
 	return newExpression(op).addOperand(expr)
 }
@@ -380,12 +688,33 @@ func addOperatorExpression(op Operator, result, current *Expression) *Expression
 }
 
 /*
- * Parse error
+ * Parse error. Pos is the position of the offending token in the query string (Pos.Line is 0 if not available),
+ * Expected is the list of expected tokens or Msg is a generic error message.
  */
-type ParseError string
+type ParseError struct {
+	Pos      scanner.Position
+	Token    string
+	Expected []string
+	Msg      string
+}
 
 func (e ParseError) Error() string {
-	return string(e)
+	msg := e.message()
+
+	if e.Pos.Line > 0 {
+		msg += fmt.Sprintf(" at line %v, column %v", e.Pos.Line, e.Pos.Column)
+	}
+
+	return msg
+}
+
+// error message, without position
+func (e ParseError) message() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+
+	return "Expected " + strings.Join(e.Expected, " or ") + ", got " + e.Token
 }
 
 type ElseParser struct {
@@ -396,31 +725,156 @@ type ElseParser struct {
 	scanner   *scanner.Scanner
 	lastToken rune
 	lastText  string
+	lastPos   scanner.Position
+
+	peeked   bool // the token after the current one has already been scanned (see peekToken)
+	peekTok  rune
+	peekText string
+	peekPos  scanner.Position
+
+	recover     bool         // report all errors as diagnostics
+	diagnostics []Diagnostic // errors and warnings
+	err         error        // parse result
+	afterPos    scanner.Position
+	clause      Keyword                                 // clause being parsed
+	fieldPos    map[Keyword]map[string]scanner.Position // first position of each identifier, by clause
+
+	nparams int                    // number of ? placeholders
+	args    []interface{}          // positional parameters
+	params  map[string]interface{} // named parameters
 }
 
 func NewParser(queryString string) *ElseParser {
-	p := &ElseParser{
+	return &ElseParser{
 		QueryString: queryString,
 		parsed:      false,
-		scanner:     &scanner.Scanner{},
+		scanner:     newScanner(queryString),
+	}
+}
+
+/*
+ * Return a scanner for ElseSQL text. Go tokens are used, skipping C style block comments (and // line comments);
+ * -- line comments are skipped by scanToken.
+ */
+func newScanner(src string) *scanner.Scanner {
+	s := &scanner.Scanner{}
+	s.Init(strings.NewReader(src))
+	s.Mode = scanner.GoTokens
+
+	s.Error = func(s *scanner.Scanner, msg string) {
+		// 'single quoted strings' are scanned as (invalid) char literals
+		if msg != "invalid char literal" {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", s.Position, msg)
+		}
+	}
+
+	return s
+}
+
+/*
+ * Return the next token, skipping -- line comments
+ */
+func scanToken(s *scanner.Scanner) rune {
+	for {
+		tok := s.Scan()
+		if tok != '-' || s.Peek() != '-' {
+			return tok
+		}
+
+		for ch := s.Next(); ch != '\n' && ch != scanner.EOF; ch = s.Next() {
+		}
+	}
+}
+
+/*
+ * Unquote a string token ("quoted", `raw` or 'single quoted')
+ */
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return strconv.Unquote(s)
+	}
+
+	var b strings.Builder
+
+	b.WriteByte('"')
+	for i := 1; i < len(s)-1; i++ {
+		switch c := s[i]; {
+		case c == '\\' && s[i+1] == '\'':
+			b.WriteByte('\'')
+			i++
+
+		case c == '\\':
+			b.WriteByte(c)
+			i++
+			b.WriteByte(s[i])
+
+		case c == '"':
+			b.WriteString(`\"`)
+
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+
+	return strconv.Unquote(b.String())
+}
+
+/*
+ * Scan a token, returning the token, its text ("" for EOF) and its position
+ */
+func (p *ElseParser) scan() (rune, string, scanner.Position) {
+	tok := scanToken(p.scanner)
+	if tok == scanner.EOF {
+		return tok, "", p.scanner.Position
 	}
 
-	p.scanner.Init(strings.NewReader(p.QueryString))
-	return p
+	return tok, p.scanner.TokenText(), p.scanner.Position
 }
 
 func (p *ElseParser) nextToken() rune {
 	if p.lastText == "" {
-		if p.lastToken = p.scanner.Scan(); p.lastToken != scanner.EOF {
-			p.lastText = p.scanner.TokenText()
+		if p.peeked {
+			p.peeked = false
+			p.lastToken, p.lastText, p.lastPos = p.peekTok, p.peekText, p.peekPos
 		} else {
-			p.lastText = ""
+			p.lastToken, p.lastText, p.lastPos = p.scan()
 		}
 	}
 
 	return p.lastToken
 }
 
+/*
+ * Return the token after the current one and its text, without consuming them
+ */
+func (p *ElseParser) peekToken() (rune, string) {
+	p.nextToken()
+
+	if !p.peeked {
+		p.peekTok, p.peekText, p.peekPos = p.scan()
+		p.peeked = true
+	}
+
+	return p.peekTok, p.peekText
+}
+
+/*
+ * Return true if the token after the current one is the keyword k
+ */
+func (p *ElseParser) beforeKeyword(k Keyword) bool {
+	tok, text := p.peekToken()
+	return tok == scanner.Ident && strings.EqualFold(text, k.String())
+}
+
+/*
+ * Return true if the token after the current one can start a select list (i.e. the current one is a modifier)
+ */
+func (p *ElseParser) beforeSelectList() bool {
+	tok, text := p.peekToken()
+	return tok == all_fields || (tok == scanner.Ident && !isReserved(text) && !strings.EqualFold(text, INTO.String()))
+}
+
 func (p *ElseParser) Query() *Query {
 	if err := p.Parse(); err == nil {
 		return &p.query
@@ -454,6 +908,10 @@ func (p *ElseParser) parseKeyword(k Keyword, optional bool) (bool, error) {
 			log.Println("got keyword", k)
 		}
 
+		if k == SELECT || clauseKeywords[k] {
+			p.clause = k
+		}
+
 		p.lastText = ""
 		return true, nil
 	}
@@ -517,17 +975,21 @@ func (p *ElseParser) parseBooleanOperator(odefault Operator) Operator {
 /*
  * Parsing failed, return a meaningful error
  */
-func (p *ElseParser) parseError(expected string) error {
+func (p *ElseParser) parseError(expected ...string) error {
+	err := ParseError{Pos: p.lastPos, Expected: expected}
+
 	switch p.lastToken {
 	case scanner.EOF:
-		return ParseError("Expected " + expected + ", got EOL")
+		err.Token = "EOL"
 
 	case scanner.Int, scanner.Float:
-		return ParseError("Expected " + expected + ", got number " + p.lastText)
+		err.Token = "number " + p.lastText
 
 	default:
-		return ParseError("Expected " + expected + ", got " + p.lastText)
+		err.Token = p.lastText
 	}
+
+	return err
 }
 
 /*
@@ -545,10 +1007,8 @@ func (p *ElseParser) parseId(skipKeyword bool) string {
 			log.Println("got id", word)
 		}
 
-		if skipKeyword {
-			if _, ok := FindKeyword(word); ok {
-				return ""
-			}
+		if skipKeyword && isReserved(word) {
+			return ""
 		}
 
 		p.lastText = ""
@@ -566,6 +1026,17 @@ func (p *ElseParser) parseIdentifier() (string, error) {
 	return nv.Name, err
 }
 
+/*
+ * Parse an index name: identifier or quoted string (for names with special characters or wildcards)
+ */
+func (p *ElseParser) parseIndexName() (string, error) {
+	if s, err := p.parseString(); err == nil {
+		return s, nil
+	}
+
+	return p.parseIdentifier()
+}
+
 /*
 * Parse IDENTIFIER ( id.id... ) with optional sort order
  */
@@ -574,6 +1045,7 @@ func (p *ElseParser) parseOrderIdentifier(sortorder bool) (NameValue, error) {
 	ident := ""
 	order := ""
 	skip := true
+	var pos scanner.Position
 
 	for {
 		//
@@ -581,6 +1053,10 @@ func (p *ElseParser) parseOrderIdentifier(sortorder bool) (NameValue, error) {
 		//
 		if state == 0 {
 			if word := p.parseId(skip); word != "" {
+				if ident == "" {
+					pos = p.lastPos
+				}
+
 				ident += word
 				state = 1
 				continue
@@ -593,7 +1069,6 @@ func (p *ElseParser) parseOrderIdentifier(sortorder bool) (NameValue, error) {
 		if state == 1 {
 			match, err := p.parseToken(id_sep, true)
 			if err != nil {
-				log.Println(err)
 				return NameValue{}, err
 			}
 
@@ -620,6 +1095,17 @@ func (p *ElseParser) parseOrderIdentifier(sortorder bool) (NameValue, error) {
 	}
 
 	if len(ident) > 0 {
+		if _, ok := p.fieldPos[p.clause][ident]; !ok {
+			if p.fieldPos == nil {
+				p.fieldPos = map[Keyword]map[string]scanner.Position{}
+			}
+			if p.fieldPos[p.clause] == nil {
+				p.fieldPos[p.clause] = map[string]scanner.Position{}
+			}
+
+			p.fieldPos[p.clause][ident] = pos
+		}
+
 		return NameValue{ident, order}, nil
 	}
 
@@ -648,6 +1134,39 @@ func (p *ElseParser) parseIdentifiers() ([]string, error) {
 	return result, nil
 }
 
+/*
+ * Parse (comma separated) list of IDENTIFIERS with (optional) field options
+ */
+func (p *ElseParser) parseFieldList(withOptions bool) (result []string, options map[string][]NameValue, err error) {
+	for {
+		id, err := p.parseIdentifier()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		result = append(result, id)
+
+		if withOptions && p.nextToken() == '(' {
+			opts, err := p.parseOptions()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if options == nil {
+				options = map[string][]NameValue{}
+			}
+
+			options[id] = opts
+		}
+
+		if match, _ := p.parseToken(list_sep, true); match == false {
+			break
+		}
+	}
+
+	return
+}
+
 /*
  * Parse (comma separated) list of IDENTIFIERS (for sort/order by)
  */
@@ -715,8 +1234,8 @@ func (p *ElseParser) parseInteger() (int, error) {
 func (p *ElseParser) parseString() (string, error) {
 	token := p.nextToken()
 
-	if token == scanner.String || token == scanner.RawString {
-		s, _ := strconv.Unquote(p.lastText)
+	if token == scanner.String || token == scanner.RawString || token == scanner.Char {
+		s, _ := unquote(p.lastText)
 		p.lastText = ""
 		if Debug {
 			log.Println("got string", s)
@@ -728,13 +1247,13 @@ func (p *ElseParser) parseString() (string, error) {
 }
 
 /*
- * Parse value (string or number)
+ * Parse value (string, number, true/false, RAW('lucene fragment') or parameter)
  */
 func (p *ElseParser) parseValue() (interface{}, error) {
 	token := p.nextToken()
 
-	if token == scanner.String || token == scanner.RawString {
-		s, _ := strconv.Unquote(p.lastText)
+	if token == scanner.String || token == scanner.RawString || token == scanner.Char {
+		s, _ := unquote(p.lastText)
 		p.lastText = ""
 		if Debug {
 			log.Println("got value", s)
@@ -760,9 +1279,94 @@ func (p *ElseParser) parseValue() (interface{}, error) {
 		return strconv.ParseFloat(n, 64)
 	}
 
+	if token == '-' {
+		p.lastText = ""
+
+		switch v, err := p.parseValue(); n := v.(type) {
+		case int:
+			return -n, err
+		case float64:
+			return -n, err
+		}
+
+		return 0, p.parseError("number")
+	}
+
+	if token == '?' || token == '$' || token == ':' {
+		return p.parseParam()
+	}
+
+	if token == scanner.Ident {
+		switch strings.ToLower(p.lastText) {
+		case "true":
+			p.lastText = ""
+			return true, nil
+
+		case "false":
+			p.lastText = ""
+			return false, nil
+
+		case "now":
+			return p.parseDateMath()
+		}
+
+		if tok, _ := p.peekToken(); tok == '(' && strings.EqualFold(p.lastText, RAW.String()) {
+			return p.parseRaw()
+		}
+	}
+
 	return 0, p.parseError("value")
 }
 
+/*
+ * Parse RAW('lucene fragment') (the current token is RAW)
+ */
+func (p *ElseParser) parseRaw() (Raw, error) {
+	p.lastText = ""
+
+	if err := p.parseParen(OPENP); err != nil {
+		return "", err
+	}
+
+	s, err := p.parseString()
+	if err != nil {
+		return "", err
+	}
+
+	return Raw(s), p.parseParen(CLOSEP)
+}
+
+/*
+ * Parse parameter placeholder (?, $n or :name)
+ */
+func (p *ElseParser) parseParam() (Param, error) {
+	token := p.nextToken()
+	p.lastText = ""
+
+	switch token {
+	case '?':
+		p.nparams++
+		return Param{Index: p.nparams}, nil
+
+	case '$':
+		n, err := p.parseInteger()
+		if err != nil || n < 1 {
+			return Param{}, p.parseError("parameter number")
+		}
+
+		return Param{Index: n}, nil
+
+	case ':':
+		if name := p.parseId(false); name != "" {
+			return Param{Name: name}, nil
+		}
+
+		return Param{}, p.parseError("parameter name")
+	}
+
+	return Param{}, p.parseError("parameter")
+}
+
 /*
  * Parse (comma separated) list of values
  */
@@ -866,8 +1470,21 @@ func (p *ElseParser) parseExpression() (*Expression, error) {
 		var expr *Expression
 		not, _ := p.parseKeyword(NOT, true)
 
-		stringExpr, _ := p.parseString()
-		if stringExpr != "" {
+		if match, _ := p.parseToken('(', true); match {
+			group, err := p.parseExpression()
+			if err != nil {
+				return nil, err
+			}
+			if group == nil {
+				return nil, p.parseError("expression")
+			}
+
+			if err := p.parseParen(CLOSEP); err != nil {
+				return nil, err
+			}
+
+			expr = group
+		} else if stringExpr, _ := p.parseString(); stringExpr != "" {
 			expr = singleOperand(STRING_EXPR, stringExpr)
 		} else if match, _ := p.parseKeyword(EXIST, true); match {
 			name, err := p.parseIdentifier()
@@ -945,6 +1562,182 @@ func (p *ElseParser) parseFilter() (*Expression, error) {
 	}
 }
 
+/*
+ * parse index [alias] ON id = id
+ */
+func (p *ElseParser) parseJoin() (*Join, error) {
+	index, err := p.parseIndexName()
+	if err != nil {
+		return nil, err
+	}
+
+	join := &Join{Index: index}
+
+	if p.beforeKeyword(ON) {
+		join.Alias = p.parseId(true)
+	}
+
+	if err := p.parseRequired(ON); err != nil {
+		return nil, err
+	}
+
+	if join.Left, err = p.parseIdentifier(); err != nil {
+		return nil, err
+	}
+
+	if op, _ := p.parseOperator(); op != EQ {
+		return nil, p.parseError("=")
+	}
+
+	if join.Right, err = p.parseIdentifier(); err != nil {
+		return nil, err
+	}
+
+	return join, nil
+}
+
+/*
+ * Split alias.field into alias and field
+ */
+func splitAlias(name string) (alias, field string) {
+	if i := strings.IndexRune(name, id_sep); i > 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
+
+/*
+ * Resolve the aliases in a JOIN statement:
+ * the select list is fully qualified (alias.field) while all the other clauses,
+ * that are executed on the FROM index, only refer to fields without alias.
+ */
+func (p *ElseParser) resolveJoin() error {
+	q := &p.query
+	j := q.Join
+
+	if q.Alias == "" {
+		q.Alias = q.Index
+	}
+	if j.Alias == "" {
+		j.Alias = j.Index
+	}
+	if q.Alias == j.Alias {
+		return ParseError{Msg: "JOIN requires distinct aliases, got " + q.Alias}
+	}
+
+	la, lf := splitAlias(j.Left)
+	ra, rf := splitAlias(j.Right)
+
+	if la == j.Alias && ra == q.Alias {
+		la, lf, ra, rf = ra, rf, la, lf
+	}
+	if la != q.Alias || ra != j.Alias {
+		return ParseError{Msg: "Expected " + q.Alias + ".field = " + j.Alias + ".field in JOIN condition"}
+	}
+
+	j.Left, j.Right = lf, rf
+
+	for i, name := range q.SelectList {
+		if a, _ := splitAlias(name); a != q.Alias && a != j.Alias {
+			q.SelectList[i] = q.Alias + string(id_sep) + name
+		}
+	}
+
+	local := func(name string) (string, error) {
+		a, f := splitAlias(name)
+		switch a {
+		case q.Alias:
+			return f, nil
+		case j.Alias:
+			return "", ParseError{Msg: "fields of joined index " + j.Alias + " can only be used in the select list, got " + name}
+		}
+
+		return name, nil
+	}
+
+	if err := q.WhereExpr.renameFields(local); err != nil {
+		return err
+	}
+	if err := q.FilterExpr.renameFields(local); err != nil {
+		return err
+	}
+
+	var err error
+
+	for i, name := range q.FacetList {
+		if q.FacetList[i], err = local(name); err != nil {
+			return err
+		}
+	}
+
+	for i, nv := range q.OrderList {
+		if nv.Name == "_script" {
+			continue
+		}
+
+		if q.OrderList[i].Name, err = local(nv.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Parse (name=value, ...) options. Values can be strings, numbers, true/false or identifiers.
+ */
+func (p *ElseParser) parseOptions() ([]NameValue, error) {
+	if err := p.parseParen(OPENP); err != nil {
+		return nil, err
+	}
+
+	var options []NameValue
+
+	for {
+		name := p.parseId(false)
+		if name == "" {
+			return nil, p.parseError("option name")
+		}
+
+		if op, _ := p.parseOperator(); op != EQ {
+			return nil, p.parseError("=")
+		}
+
+		var value interface{}
+
+		if id := p.parseId(false); id != "" {
+			switch strings.ToLower(id) {
+			case "true":
+				value = true
+			case "false":
+				value = false
+			default:
+				value = id
+			}
+		} else {
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+
+			value = v
+		}
+
+		options = append(options, NameValue{name, value})
+
+		if match, _ := p.parseToken(list_sep, true); match == false {
+			break
+		}
+	}
+
+	if err := p.parseParen(CLOSEP); err != nil {
+		return nil, err
+	}
+
+	return options, nil
+}
+
 /*
  * parse scriptId = "script expression"
  */
@@ -971,61 +1764,218 @@ func (p *ElseParser) parseScript() (*NameValue, error) {
  */
 func (p *ElseParser) Parse() (err error) {
 	if p.parsed {
-		return
+		return p.err
 	}
 
 	p.parsed = true
+	p.err = p.parseStatement()
+	return p.err
+}
+
+/*
+ * Parse the statement reporting all the syntax errors: after an error the parser skips to the next
+ * clause keyword (FROM, WHERE, FILTER, ORDER, LIMIT...) and continues parsing.
+ * Returns the (partial) query and the list of diagnostics, sorted by position.
+ */
+func (p *ElseParser) ParseDiagnostics() (*Query, []Diagnostic) {
+	if !p.parsed {
+		p.recover = true
+		p.parsed = true
+		p.err = p.parseStatement()
+	}
+
+	return &p.query, p.diagnostics
+}
+
+/*
+ * Parse the statement clauses in order. In recovery mode errors are collected as diagnostics.
+ */
+func (p *ElseParser) parseStatement() error {
+	var err error
+
+	if p.query.Command = p.parseKeywords(commandKeywords, SELECT); p.query.Command != SELECT {
+		err = p.parseClauses(p.commandClauses())
+	} else {
+		err = p.parseClauses(append([]func() error{p.parseExplain}, p.selectClauses()...))
+	}
 
+	if p.recover {
+		p.lint()
+		sortDiagnostics(p.diagnostics)
+		return p.firstError()
+	}
+
+	return err
+}
+
+/*
+ * Return the clauses of a SELECT statement (also used as the source of INSERT INTO ... SELECT)
+ */
+func (p *ElseParser) selectClauses() []func() error {
+	return []func() error{
+		p.parseSelectList,
+		p.parseInto,
+		p.parseFacets,
+		p.parseScriptClause,
+		p.parseFrom,
+		p.parseWhere,
+		p.parseTimeRange,
+		p.parseFilterClause,
+		p.parseHighlight,
+		p.parseOrder,
+		p.parseLimit,
+		p.parseAfter,
+		p.parseEnd,
+		p.bindParams,
+		p.resolveStatement,
+	}
+}
+
+func (p *ElseParser) parseClauses(clauses []func() error) error {
+	for _, clause := range clauses {
+		if err := clause(); err != nil {
+			if !p.recover {
+				return err
+			}
+
+			p.addDiagnostic(SeverityError, err)
+			p.synchronize()
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Skip tokens until EOF or the next clause keyword
+ */
+func (p *ElseParser) synchronize() {
+	for {
+		t := p.nextToken()
+		if t == scanner.EOF {
+			return
+		}
+
+		if t == scanner.Ident && p.atClause() {
+			return
+		}
+
+		p.lastText = "" // skip
+	}
+}
+
+func (p *ElseParser) parseExplain() (err error) {
+	if p.query.Explain, _ = p.parseKeyword(EXPLAIN, true); p.query.Explain {
+		p.query.Analyze, _ = p.parseKeyword(ANALYZE, true)
+	}
+
+	return
+}
+
+func (p *ElseParser) parseSelectList() (err error) {
 	if err = p.parseRequired(SELECT); err != nil {
 		return
 	}
 
+	// DISTINCT, FIELDS, DOCVALUES and STORED are only modifiers if they are followed by the select list
+	if p.beforeSelectList() {
+		p.query.Distinct, _ = p.parseKeyword(DISTINCT, true)
+	}
+
+	p.query.Retrieve = NO_KEYWORD
+	if p.beforeSelectList() {
+		p.query.Retrieve = p.parseKeywords([]Keyword{FIELDS, DOCVALUES, STORED}, NO_KEYWORD)
+	}
+
 	if match, _ := p.parseToken(all_fields, true); match {
+		if p.query.Distinct {
+			return p.parseError("list of fields")
+		}
+
 		p.query.SelectList = nil // all fields
+	} else if p.query.Retrieve != NO_KEYWORD {
+		if p.query.Distinct {
+			return ParseError{Pos: p.lastPos, Msg: "DISTINCT is not supported with " + p.query.Retrieve.String()}
+		}
+
+		p.query.SelectList, p.query.FieldOptions, err = p.parseFieldList(p.query.Retrieve != STORED)
 	} else {
 		p.query.SelectList, err = p.parseIdentifiers()
-		if err != nil {
-			return
-		}
 	}
 
+	return
+}
+
+func (p *ElseParser) parseFacets() (err error) {
 	if match, _ := p.parseKeyword(FACETS, true); match {
 		p.query.FacetList, err = p.parseIdentifiers()
-		if err != nil {
-			return
-		}
 	}
 
+	return
+}
+
+func (p *ElseParser) parseScriptClause() (err error) {
 	if match, _ := p.parseKeyword(SCRIPT, true); match {
 		p.query.Script, err = p.parseScript()
-		if err != nil {
-			return
-		}
 	}
 
+	return
+}
+
+func (p *ElseParser) parseFrom() (err error) {
 	if err = p.parseRequired(FROM); err != nil {
 		return
 	}
 
-	p.query.Index, err = p.parseIdentifier()
+	p.query.Index, err = p.parseIndexName()
 	if err != nil {
 		return
 	}
 
+	// the index can only have an alias in a JOIN statement
+	if p.beforeKeyword(JOIN) {
+		p.query.Alias = p.parseId(true)
+	}
+
+	if match, _ := p.parseKeyword(JOIN, true); match {
+		p.query.Join, err = p.parseJoin()
+	}
+
+	return
+}
+
+func (p *ElseParser) parseWhere() (err error) {
 	if match, _ := p.parseKeyword(WHERE, true); match {
 		p.query.WhereExpr, err = p.parseExpression()
-		if err != nil {
-			return
-		}
 	}
 
+	return
+}
+
+func (p *ElseParser) parseFilterClause() (err error) {
 	if match, _ := p.parseKeyword(FILTER, true); match {
 		p.query.FilterExpr, err = p.parseFilter()
+	}
+
+	return
+}
+
+func (p *ElseParser) parseHighlight() (err error) {
+	if match, _ := p.parseKeyword(HIGHLIGHT, true); match {
+		p.query.HighlightList, err = p.parseIdentifiers()
 		if err != nil {
 			return
 		}
+
+		if p.nextToken() == '(' {
+			p.query.HighlightOptions, err = p.parseOptions()
+		}
 	}
 
+	return
+}
+
+func (p *ElseParser) parseOrder() (err error) {
 	if match, _ := p.parseKeyword(ORDER, true); match {
 		if err = p.parseRequired(BY); err != nil {
 			return
@@ -1039,42 +1989,78 @@ func (p *ElseParser) Parse() (err error) {
 			p.query.OrderList = []NameValue{
 				NameValue{"_script", decodeObject(script)},
 			}
-		} else if p.query.OrderList, err = p.parseOrderIdentifiers(); err != nil {
-			return
+		} else {
+			p.query.OrderList, err = p.parseOrderIdentifiers()
 		}
 	}
 
+	return
+}
+
+func (p *ElseParser) parseLimit() error {
+	p.query.Size = -1
+
 	if match, _ := p.parseKeyword(LIMIT, true); match {
-		v, ierr := p.parseInteger()
-		if ierr != nil {
-			return ierr
+		v, err := p.parseInteger()
+		if err != nil {
+			return err
 		}
 
 		if match, _ := p.parseToken(list_sep, true); match {
 			p.query.From = v
-			v, ierr = p.parseInteger()
-			if ierr != nil {
-				return ierr
+			v, err = p.parseInteger()
+			if err != nil {
+				return err
 			}
 		}
 
 		p.query.Size = v
-	} else {
-		p.query.Size = -1
 	}
 
+	return nil
+}
+
+func (p *ElseParser) parseAfter() error {
+	p.nextToken()
+	p.afterPos = p.lastPos
+
 	if match, _ := p.parseKeyword(AFTER, true); match {
-		v, perr := p.parseString()
-		if perr != nil {
-			return perr
+		v, err := p.parseString()
+		if err != nil {
+			return err
 		}
 
 		p.query.After = v
 	}
 
+	return nil
+}
+
+func (p *ElseParser) parseEnd() error {
 	if !p.parseDone() {
 		return p.parseError("EOF")
 	}
 
 	return nil
 }
+
+/*
+ * Validate and resolve the parsed statement
+ */
+func (p *ElseParser) resolveStatement() error {
+	if p.query.Target != "" && p.query.Command == SELECT {
+		if err := p.query.checkInto(); err != nil {
+			return err
+		}
+	}
+
+	if p.query.Join != nil {
+		if p.query.Distinct {
+			return ParseError{Msg: "DISTINCT is not supported with JOIN"}
+		}
+
+		return p.resolveJoin()
+	}
+
+	return nil
+}
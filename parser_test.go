@@ -35,3 +35,262 @@ func TestParse3(t *testing.T) {
 		t.Log(parser.Query().String())
 	}
 }
+
+func TestParseJoin(t *testing.T) {
+	parser := NewParser("SELECT o.id, c.name, total FROM orders o JOIN customers c ON o.customer_id = c.id WHERE o.total > 10")
+	t.Log(parser.QueryString)
+
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	q := parser.Query()
+	t.Log(q.String())
+
+	if q.Join == nil || q.Join.Left != "customer_id" || q.Join.Right != "id" {
+		t.Errorf("unexpected join %v", q.Join)
+	}
+
+	if q.SelectList[2] != "o.total" {
+		t.Errorf("expected o.total, got %v", q.SelectList[2])
+	}
+
+	if qs := q.WhereExpr.QueryString(); qs != "total:{10 TO *}" {
+		t.Errorf("unexpected where %v", qs)
+	}
+
+	if err := NewParser("SELECT * FROM orders o JOIN customers c ON o.id = c.id WHERE c.name = \"x\"").Parse(); err == nil {
+		t.Error("expected error for WHERE on joined index")
+	}
+
+	if err := NewParser("SELECT orders.id, customers.name FROM orders JOIN customers ON orders.customer_id = customers.id").Parse(); err != nil {
+		t.Error(err)
+	}
+
+	if err := NewParser("SELECT a FROM orders o").Parse(); err == nil {
+		t.Error("expected error for alias without JOIN")
+	}
+}
+
+func TestParseContextKeywords(t *testing.T) {
+	// keywords that are only recognized in their statement position can be used as field names
+	for _, statement := range []string{
+		"SELECT on FROM t WHERE on = 1 ORDER BY on",
+		"SELECT join FROM t WHERE join = 1 ORDER BY join",
+		"SELECT a FROM orders o JOIN customers join ON o.customer = join.id",
+		"SELECT distinct FROM t WHERE distinct = 1 ORDER BY distinct",
+		"SELECT DISTINCT distinct FROM t",
+		"SELECT highlight FROM t WHERE highlight = 1 HIGHLIGHT highlight ORDER BY highlight",
+		"SELECT fields, stored.x FROM t WHERE stored = 1 ORDER BY docvalues",
+		"SELECT raw, message.raw FROM t WHERE raw = 1 AND message.raw = RAW('a*') ORDER BY raw",
+		"SELECT analyze, explain FROM t WHERE explain = 1 ORDER BY analyze",
+		"EXPLAIN ANALYZE SELECT analyze FROM t",
+		"SELECT stats, like, show FROM t WHERE like = 'a' AND stats.count > 2 ORDER BY describe, indices, aliases",
+		"SELECT values, into, insert FROM t WHERE values = 1 ORDER BY into",
+		"SELECT set, update FROM t WHERE delete = true ORDER BY set",
+		"SELECT index, add, with FROM t WHERE index = 1 AND create > 0 ORDER BY index, drop, alter",
+		"SELECT fields INTO copy FROM t",
+		"SELECT a INTO copy SET set = update FROM t",
+		"SELECT index FROM t SINCE 'now-1d' WITH (field=ts)",
+		"SELECT to, for, swap FROM t WHERE alias = 'x' AND rollover = false ORDER BY to",
+		"SELECT since, until FROM t WHERE last = 1 AND during > 2 ORDER BY since",
+		"SELECT last FROM t WHERE since > 1 SINCE 'now-1d' UNTIL 'now'",
+		"SELECT * FROM t DURING LAST 1d",
+	} {
+		if err := NewParser(statement).Parse(); err != nil {
+			t.Errorf("%v: %v", statement, err)
+		}
+	}
+}
+
+func TestParseDistinct(t *testing.T) {
+	jq, _, columns, err := ParseQuery("SELECT DISTINCT a, b FROM table WHERE x > 1 ORDER BY b DESC LIMIT 20", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(jq)
+
+	composite := jq["aggs"].(jmap)[distinctAgg].(jmap)["composite"].(jmap)
+	if composite["size"] != 20 || len(composite["sources"].(jarr)) != 2 || len(columns) != 2 {
+		t.Errorf("unexpected composite aggregation %v", composite)
+	}
+
+	// sources follow the ORDER BY priority, columns the select list
+	if first := composite["sources"].(jarr)[0].(jmap); first["b"] == nil || columns[0] != "a" {
+		t.Errorf("expected b as first source, got %v (columns %v)", composite["sources"], columns)
+	}
+
+	jq, _, _, err = ParseQuery("SELECT DISTINCT a FROM table", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if jq["collapse"].(jmap)["field"] != "a" {
+		t.Errorf("expected collapse on a, got %v", jq)
+	}
+
+	if _, _, _, err := ParseQuery("SELECT DISTINCT * FROM table", ""); err == nil {
+		t.Error("expected error for DISTINCT *")
+	}
+
+	if q := NewParser("SELECT distinct FROM table").Query(); q == nil || q.Distinct || len(q.SelectList) != 1 || q.SelectList[0] != "distinct" {
+		t.Errorf("expected distinct as a field, got %v", q)
+	}
+}
+
+func TestParseHighlight(t *testing.T) {
+	jq, _, columns, err := ParseQuery("SELECT title FROM table WHERE body = 'it\\'s' HIGHLIGHT body, title (fragment_size=150, pre='<b>', post='</b>')", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(jq, columns)
+
+	hl := jq["highlight"].(jmap)
+	if hl["fragment_size"] != 150 || hl["pre_tags"].(jarr)[0] != "<b>" || len(hl["fields"].(jmap)) != 2 {
+		t.Errorf("unexpected highlight %v", hl)
+	}
+
+	if len(columns) != 3 || columns[1] != "body.highlight" {
+		t.Errorf("unexpected columns %v", columns)
+	}
+}
+
+func TestParseMetaFields(t *testing.T) {
+	jq, _, columns, err := ParseQuery("SELECT _id, _index, name, _version, _seq_no FROM table ORDER BY name", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(jq, columns)
+
+	if source := jq["_source"].([]string); len(source) != 1 || source[0] != "name" {
+		t.Errorf("unexpected _source %v", jq["_source"])
+	}
+
+	if jq["version"] != true || jq["seq_no_primary_term"] != true || len(columns) != 5 {
+		t.Errorf("unexpected query %v", jq)
+	}
+
+	doc := hitDocument(jmap{"_id": "1", "_index": "table", "_source": jmap{"name": "x"}}, &Query{SelectList: columns}, List)
+	if doc["_id"] != "1" || doc["name"] != "x" {
+		t.Errorf("unexpected document %v", doc)
+	}
+}
+
+func TestParseRetrieve(t *testing.T) {
+	jq, _, columns, err := ParseQuery("SELECT DOCVALUES ts(format=epoch_millis), b, _id FROM table", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(jq, columns)
+
+	dv := jq["docvalue_fields"].(jarr)
+	if len(dv) != 2 || dv[0].(jmap)["format"] != "epoch_millis" || dv[1] != "b" || jq["_source"] != false {
+		t.Errorf("unexpected docvalue_fields %v", jq)
+	}
+
+	hit := jmap{"_id": "1", "fields": jmap{"ts": jarr{1234.0}, "b": jarr{"x", "y"}}}
+	doc := hitDocument(hit, &Query{Retrieve: DOCVALUES, SelectList: columns}, List)
+	if doc["ts"] != 1234.0 || len(doc["b"].(jarr)) != 2 || doc["_id"] != "1" {
+		t.Errorf("unexpected document %v", doc)
+	}
+
+	if jq, _, _, _ := ParseQuery("SELECT STORED * FROM table", ""); jq["stored_fields"].(jarr)[0] != "*" {
+		t.Errorf("unexpected stored_fields %v", jq)
+	}
+
+	if q := NewParser("SELECT fields FROM table").Query(); q == nil || q.Retrieve != NO_KEYWORD || q.SelectList[0] != "fields" {
+		t.Errorf("expected fields column, got %v", q)
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	parser := NewParser("SELECT * FROM table WHERE x = ? AND y > $2 AND z IN (:ids) AND w = :name").
+		Bind("a (b) OR c:*", 3.5).
+		BindNamed(map[string]interface{}{"ids": []int{1, 2, 3}, "name": "n"})
+
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log(parser.Query().WhereExpr)
+
+	ops := parser.Query().WhereExpr.operands
+	if nv := ops[0].(*Expression).operands[0].(NameValue); nv.Value != "a (b) OR c:*" {
+		t.Errorf("unexpected value for ?: %v", nv)
+	}
+	if nv := ops[2].(*Expression).operands[0].(NameValue); len(nv.Value.([]interface{})) != 3 {
+		t.Errorf("unexpected value for :ids: %v", nv)
+	}
+
+	if _, _, _, err := ParseQuery("SELECT * FROM table WHERE x = :missing", "", WithArgs(1)); err == nil {
+		t.Error("expected error for missing parameter")
+	}
+
+	if _, _, _, err := ParseQuery("SELECT * FROM table WHERE x = $1", "", WithArgs([]string{"a"})); err == nil {
+		t.Error("expected error for list parameter")
+	}
+
+	if _, _, _, err := ParseQuery("SELECT * FROM table WHERE x = $1", "", WithArgs(uint64(1<<63))); err == nil {
+		t.Error("expected error for out of range parameter")
+	}
+
+	if _, _, _, err := ParseQuery("SELECT * FROM table WHERE x IN (:ids)", "", WithParams(map[string]interface{}{"ids": []int{}})); err == nil {
+		t.Error("expected error for empty list parameter")
+	}
+}
+
+func TestParseErrorPosition(t *testing.T) {
+	err := NewParser("SELECT a, b\nFROM table\nWHERE x = 1 ORDER name").Parse()
+	perr, ok := err.(ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+
+	t.Log(perr)
+
+	if perr.Pos.Line != 3 || perr.Pos.Column != 19 || perr.Token != "name" || perr.Expected[0] != "BY" {
+		t.Errorf("unexpected error %#v", perr)
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	parser := NewParser("SELECT a, FROM table WHERE x = LIMIT z AFTER 'abc'")
+
+	q, diagnostics := parser.ParseDiagnostics()
+	for _, d := range diagnostics {
+		t.Log(d)
+	}
+
+	if len(diagnostics) != 4 {
+		t.Fatalf("expected 4 diagnostics, got %v", len(diagnostics))
+	}
+
+	if d := diagnostics[0]; d.Severity != SeverityError || d.Pos.Column != 11 {
+		t.Errorf("unexpected diagnostic %v", d)
+	}
+
+	if d := diagnostics[3]; d.Severity != SeverityWarning {
+		t.Errorf("expected warning, got %v", d)
+	}
+
+	if q.Index != "table" || q.After != "abc" {
+		t.Errorf("unexpected partial query %v", q)
+	}
+
+	if err := parser.Parse(); err == nil {
+		t.Error("expected error from Parse")
+	}
+
+	// since is a field here, not a place to resume parsing
+	if _, diagnostics := NewParser("SELECT a FROM table WHERE x = AND since = 1 LIMIT 5").ParseDiagnostics(); len(diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic, got %v", diagnostics)
+	}
+
+	if q, diagnostics := NewParser("SELECT a FROM table WHERE x = AND SINCE 'now-1d' LIMIT 5").ParseDiagnostics(); len(diagnostics) != 1 || q.TimeRange == nil || q.Size != 5 {
+		t.Errorf("expected to resume at SINCE, got %v %v", diagnostics, q)
+	}
+}
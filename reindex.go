@@ -0,0 +1,225 @@
+package elseql
+
+import (
+	"strings"
+)
+
+/*
+ * SELECT INTO statements:
+ *
+ *   SELECT a, b INTO new_index [SET c = a * b] FROM index WHERE expr LIMIT n
+ *
+ * The documents matching the WHERE clause are copied to the new index with _reindex:
+ * the select list is used as the _source includes, the SET assignments (computed columns) are compiled to a painless script
+ * and LIMIT sets max_docs. The request is started as a task and polled until it completes (see WaitTask).
+ */
+
+var (
+	reindexColumns = []string{"total", "created", "updated", "version_conflicts", "noops", "failures"}
+)
+
+func (p *ElseParser) parseInto() (err error) {
+	if match, _ := p.parseKeyword(INTO, true); !match {
+		return
+	}
+	if p.query.Command == INSERT {
+		return ParseError{Pos: p.lastPos, Msg: "INTO is not supported in INSERT"}
+	}
+
+	if p.query.Target, err = p.parseIndexName(); err != nil {
+		return
+	}
+
+	if match, _ := p.parseKeyword(SET, true); match {
+		p.query.SetList, err = p.parseAssignments()
+	}
+
+	return
+}
+
+/*
+ * Check that the query only uses the clauses supported by SELECT INTO
+ */
+func (q *Query) checkInto() error {
+	var unsupported []string
+
+	if q.Distinct {
+		unsupported = append(unsupported, DISTINCT.String())
+	}
+	if retrieveKey(q.Retrieve) != "" {
+		unsupported = append(unsupported, q.Retrieve.String())
+	}
+	if len(q.FacetList) > 0 {
+		unsupported = append(unsupported, FACETS.String())
+	}
+	if q.Script != nil {
+		unsupported = append(unsupported, SCRIPT.String()+" (use SET)")
+	}
+	if q.Join != nil {
+		unsupported = append(unsupported, JOIN.String())
+	}
+	if q.FilterExpr != nil {
+		unsupported = append(unsupported, FILTER.String())
+	}
+	if len(q.HighlightList) > 0 {
+		unsupported = append(unsupported, HIGHLIGHT.String())
+	}
+	if len(q.OrderList) > 0 {
+		unsupported = append(unsupported, ORDER.String()+" "+BY.String())
+	}
+	if q.From > 0 {
+		unsupported = append(unsupported, "LIMIT offset")
+	}
+	if q.After != "" {
+		unsupported = append(unsupported, AFTER.String())
+	}
+
+	if len(unsupported) > 0 {
+		return ParseError{Msg: strings.Join(unsupported, ", ") + " not supported with INTO"}
+	}
+
+	return nil
+}
+
+/*
+ * Add the fields referenced by a SET value to fields
+ */
+func setFields(v interface{}, fields []string) []string {
+	switch vv := v.(type) {
+	case FieldRef:
+		return append(fields, string(vv))
+
+	case *SetExpression:
+		return setFields(vv.Right, setFields(vv.Left, fields))
+	}
+
+	return fields
+}
+
+/*
+ * Return the painless statement that removes a field from the document
+ */
+func removeField(name string) string {
+	if i := strings.LastIndexByte(name, id_sep); i > 0 {
+		return sourceField(name[:i]) + "?.remove('" + name[i+1:] + "');"
+	}
+
+	return "ctx._source.remove('" + name + "');"
+}
+
+/*
+ * Return the _source includes (nil for all fields) and the script for a SELECT INTO statement.
+ * Fields referenced by the SET values that are not in the select list are included for the script
+ * and then removed from the copied document.
+ */
+func (q *Query) intoSource() (includes []string, script jmap) {
+	source, _ := splitMetaFields(q.SelectList)
+	selected := map[string]bool{}
+	for _, f := range source {
+		selected[f] = true
+	}
+
+	for _, nv := range q.SetList {
+		selected[nv.Name] = true // computed columns are never removed
+	}
+
+	var extra []string
+	for _, nv := range q.SetList {
+		for _, f := range setFields(nv.Value, nil) {
+			if !selected[f] {
+				selected[f] = true
+				extra = append(extra, f)
+			}
+		}
+	}
+
+	if len(source) > 0 {
+		includes = append(source, extra...)
+	}
+
+	if len(q.SetList) > 0 {
+		script = setScript(q.SetList)
+
+		if len(source) > 0 {
+			removals := make([]string, 0, len(extra))
+			for _, f := range extra {
+				removals = append(removals, removeField(f))
+			}
+			if len(removals) > 0 {
+				script["source"] = script["source"].(string) + " " + strings.Join(removals, " ")
+			}
+		}
+	}
+
+	return
+}
+
+/*
+ * Return the _reindex request for a SELECT INTO statement (jq is the translated search request)
+ */
+func reindexRequest(query *Query, jq jmap) jmap {
+	source := jmap{
+		"index": strings.SplitN(query.Index, string(id_sep), 2)[0], // drop the document type
+		"query": jq["query"],
+	}
+
+	includes, script := query.intoSource()
+	if includes != nil {
+		source["_source"] = includes
+	}
+
+	body := jmap{
+		"source": source,
+		"dest":   jmap{"index": query.Target},
+	}
+
+	if script != nil {
+		body["script"] = script
+	}
+	if query.Size >= 0 {
+		body["max_docs"] = query.Size
+	}
+
+	return body
+}
+
+/*
+ * Execute a SELECT INTO statement (or count the matching documents for a dry run)
+ */
+func (es *ElseSearch) selectInto(query *Query, jq jmap, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	if err := checkIndex(query.Index, queryString); err != nil {
+		return nil, err
+	}
+	if err := checkIndex(query.Target, queryString); err != nil {
+		return nil, err
+	}
+
+	body := reindexRequest(query, jq)
+
+	if opts.dryRun {
+		index := body["source"].(jmap)["index"].(string)
+		return es.dryRun(index, jmap{"query": jq["query"]}, query.Size, nilValue, returnType)
+	}
+
+	params := map[string]interface{}{"wait_for_completion": "false"}
+	if opts.refresh != "" {
+		params["refresh"] = opts.refresh
+	}
+
+	res, err := es.request("POST", "_reindex", params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := taskId(res, "POST _reindex")
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := es.WaitTask(task, opts.timeout(), opts.progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return byQueryResult(response, reindexColumns, nilValue, returnType)
+}
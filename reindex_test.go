@@ -0,0 +1,82 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseInto(t *testing.T) {
+	statement := "SELECT a, b INTO 'new-idx' SET total = price * qty FROM idx WHERE a = 1 LIMIT 100"
+
+	parser := NewParser(statement)
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	q := parser.Query()
+	if q.isCommand() || q.Target != "new-idx" || len(q.SetList) != 1 {
+		t.Errorf("unexpected query %v", q)
+	}
+
+	expected := "SELECT a, b\nINTO \"new-idx\"\nSET total = price * qty\nFROM idx\nWHERE a = 1\nLIMIT 100"
+	if f := Format(q); f != expected {
+		t.Errorf("expected %q, got %q", expected, f)
+	}
+
+	for _, statement := range []string{
+		"SELECT a INTO copy FROM idx ORDER BY a",
+		"SELECT DISTINCT a INTO copy FROM idx",
+		"SELECT a INTO copy FROM idx LIMIT 10, 10",
+		"INSERT INTO copy SELECT a INTO other FROM idx",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+
+	if _, _, _, err := ParseQuery("SELECT a INTO b FROM c", ""); err == nil {
+		t.Error("expected error for ParseQuery(SELECT INTO)")
+	}
+}
+
+func TestReindexRequest(t *testing.T) {
+	parser := NewParser("SELECT a, _id INTO copy SET total = price * a FROM idx WHERE a = 1 LIMIT 10")
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	q := parser.Query()
+	jq, _, _, err := q.SearchRequest("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := reindexRequest(q, jq)
+
+	source := body["source"].(jmap)
+	if source["index"] != "idx" || !reflect.DeepEqual(source["_source"], []string{"a", "price"}) || source["query"] == nil {
+		t.Errorf("unexpected source %v", source)
+	}
+	if !reflect.DeepEqual(body["dest"], jmap{"index": "copy"}) || body["max_docs"] != 10 {
+		t.Errorf("unexpected request %v", body)
+	}
+
+	script := body["script"].(jmap)
+	if s := script["source"]; s != "ctx._source['total'] = (ctx._source['price'] * ctx._source['a']); ctx._source.remove('price');" {
+		t.Errorf("unexpected script %v", s)
+	}
+
+	parser = NewParser("SELECT * INTO copy FROM idx")
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	body = reindexRequest(parser.Query(), jmap{"query": jmap{"match_all": jmap{}}})
+	if _, ok := body["source"].(jmap)["_source"]; ok || body["script"] != nil || body["max_docs"] != nil {
+		t.Errorf("unexpected request %v", body)
+	}
+
+	if s := removeField("a.b.c"); s != "ctx._source['a']['b']?.remove('c');" {
+		t.Errorf("unexpected statement %v", s)
+	}
+}
@@ -0,0 +1,101 @@
+package elseql
+
+import (
+	"strings"
+	"text/scanner"
+)
+
+/*
+ * Scripts: multiple statements separated by ';' (i.e. a file of saved queries).
+ */
+
+const stmt_sep = ';'
+
+/*
+ * A statement in a script
+ */
+type Statement struct {
+	Pos   scanner.Position // position of the statement in the script
+	Text  string
+	Query *Query // nil if the statement is invalid
+	Err   error  // parse error, with the position in the script
+}
+
+/*
+ * Split a script in statements (empty statements and comments are skipped)
+ */
+func SplitScript(script string) []Statement {
+	var statements []Statement
+
+	s := newScanner(script)
+	s.Error = func(*scanner.Scanner, string) {} // reported when the statement is parsed
+
+	var start scanner.Position
+
+	add := func(end int) {
+		if start.Line > 0 {
+			statements = append(statements, Statement{
+				Pos:  start,
+				Text: strings.TrimSpace(script[start.Offset:end]),
+			})
+		}
+
+		start = scanner.Position{}
+	}
+
+	for tok := scanToken(s); tok != scanner.EOF; tok = scanToken(s) {
+		if tok == stmt_sep {
+			add(s.Position.Offset)
+		} else if start.Line == 0 {
+			start = s.Position
+		}
+	}
+
+	add(len(script))
+	return statements
+}
+
+/*
+ * Split a script in statements and parse each statement.
+ * Returns the statements and the first error (statements after an invalid one are still parsed).
+ */
+func ParseScript(script string) ([]Statement, error) {
+	statements := SplitScript(script)
+	var firstErr error
+
+	for i := range statements {
+		st := &statements[i]
+
+		parser := NewParser(st.Text)
+		if err := parser.Parse(); err != nil {
+			st.Err = st.scriptError(err)
+			if firstErr == nil {
+				firstErr = st.Err
+			}
+
+			continue
+		}
+
+		st.Query = parser.Query()
+	}
+
+	return statements, firstErr
+}
+
+/*
+ * Convert the position of a parse error from the statement to the script
+ */
+func (st *Statement) scriptError(err error) error {
+	perr, ok := err.(ParseError)
+	if !ok || perr.Pos.Line == 0 {
+		return err
+	}
+
+	if perr.Pos.Line == 1 {
+		perr.Pos.Column += st.Pos.Column - 1
+	}
+
+	perr.Pos.Line += st.Pos.Line - 1
+	perr.Pos.Offset += st.Pos.Offset
+	return perr
+}
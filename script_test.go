@@ -0,0 +1,59 @@
+package elseql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseComments(t *testing.T) {
+	parser := NewParser(`-- all the documents
+SELECT a, b /* the fields */ FROM table -- the index
+WHERE x = 'a -- b' AND y > 1`)
+
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	if q := parser.Query().WhereExpr.QueryString(); q != `x:"a -- b" AND y:{1 TO *}` {
+		t.Errorf("unexpected where %v", q)
+	}
+}
+
+func TestParseScript(t *testing.T) {
+	script := `-- saved queries
+SELECT * FROM a WHERE x = 'a;b';
+
+/* second */ SELECT x FROM b ORDER BY x;;
+SELECT FROM c;
+`
+
+	statements, err := ParseScript(script)
+	if len(statements) != 3 {
+		t.Fatalf("expected 3 statements, got %v", statements)
+	}
+
+	expected := []struct {
+		line, column int
+		text         string
+	}{
+		{2, 1, "SELECT * FROM a WHERE x = 'a;b'"},
+		{4, 14, "SELECT x FROM b ORDER BY x"},
+		{5, 1, "SELECT FROM c"},
+	}
+
+	for i, st := range statements {
+		e := expected[i]
+		if st.Pos.Line != e.line || st.Pos.Column != e.column || st.Text != e.text {
+			t.Errorf("expected %v:%v %q, got %v %q", e.line, e.column, e.text, st.Pos, st.Text)
+		}
+	}
+
+	if statements[0].Query == nil || statements[1].Query == nil || statements[2].Query != nil {
+		t.Errorf("unexpected parse results %v", statements)
+	}
+
+	var perr ParseError
+	if !errors.As(err, &perr) || perr.Pos.Line != 5 || perr.Pos.Column != 8 {
+		t.Errorf("unexpected error %v (%v)", err, perr.Pos)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/gobs/httpclient"
 	"github.com/gobs/simplejson"
@@ -17,6 +18,9 @@ type jarr = []interface{}
 
 type ElseSearch struct {
 	client *httpclient.HttpClient
+
+	mu       sync.Mutex
+	mappings map[string]Mapping // cached index mappings
 }
 
 func NewClient(endpoint string) *ElseSearch {
@@ -58,6 +62,17 @@ func getpath(m jmap, k string) (ret jobj) {
 	return getparts(m, parts)
 }
 
+/*
+ * Return the value for k, as a top level key (i.e. "a.b") or as a path
+ */
+func getvalue(m jmap, k string) jobj {
+	if val, ok := m[k]; ok {
+		return val
+	}
+
+	return getpath(m, k)
+}
+
 func getparts(o jobj, parts []string) (ret jobj) {
 	ret = o
 	for pk, k := range parts {
@@ -131,9 +146,38 @@ func (e SearchError) Error() string {
 	return fmt.Sprintf("Error: %q Query: %v", e.Err, e.Query)
 }
 
+func (e SearchError) Unwrap() error {
+	return e.Err
+}
+
 // Parse an ElseSQL query and return an ElasticSearch query object, the index and the list of columns to return
-func ParseQuery(queryString, after string) (jq jmap, index string, columns []string, sErr error) {
-	parser := NewParser(queryString)
+// (see also Query.SearchRequest).
+// For a JOIN statement the query object is the one for the FROM index.
+// Values for parameter placeholders (?, $n, :name) can be passed with the WithArgs and WithParams options.
+// Commands and SELECT INTO statements (that are executed as a reindex) return an error.
+func ParseQuery(queryString, after string, options ...QueryOption) (jq jmap, index string, columns []string, sErr error) {
+	query, jq, index, columns, sErr := parseQuery(queryString, after, getQueryOptions(options))
+	if sErr != nil {
+		return
+	}
+
+	if query.isCommand() {
+		sErr = SearchError{
+			Err:   ParseError{Msg: query.Command.String() + " is not a query"},
+			Query: queryString,
+		}
+	} else if query.Target != "" { // the search request is only the source of a reindex
+		sErr = SearchError{
+			Err:   ParseError{Msg: SELECT.String() + " " + INTO.String() + " is not a query"},
+			Query: queryString,
+		}
+	}
+
+	return
+}
+
+func parseQuery(queryString, after string, opts *queryOptions) (query *Query, jq jmap, index string, columns []string, sErr error) {
+	parser := NewParser(queryString).Bind(opts.args...).BindNamed(opts.params)
 
 	if err := parser.Parse(); err != nil {
 		sErr = SearchError{
@@ -143,16 +187,77 @@ func ParseQuery(queryString, after string) (jq jmap, index string, columns []str
 		return
 	}
 
-	query := parser.Query()
+	query = parser.Query()
+	if query.isCommand() { // executed by ElseSearch.command
+		return
+	}
+
+	jq, index, columns, sErr = translateQuery(query, queryString, after, opts)
+	return
+}
+
+/*
+ * Return the ElasticSearch query object, the index and the list of columns to return for a query
+ * (values for parameter placeholders can be passed with the WithArgs and WithParams options)
+ */
+func (q *Query) SearchRequest(after string, options ...QueryOption) (jq jmap, index string, columns []string, err error) {
+	return translateQuery(q, Format(q), after, getQueryOptions(options))
+}
+
+/*
+ * Return a copy of the query with the rewrites requested by the options (keyword fields, optimizer)
+ * and the list of optimizer steps
+ */
+func prepareQuery(query *Query, opts *queryOptions) (*Query, []OptimizerStep) {
+	if opts.noOptimizer && !opts.keywordFields {
+		return query, nil
+	}
+
+	rewritten := *query
+
+	if opts.keywordFields && opts.mapping != nil {
+		rewritten.UseKeywordFields(opts.mapping)
+	}
+	if opts.noOptimizer {
+		return &rewritten, nil
+	}
+
+	return &rewritten, rewritten.Optimize()
+}
+
+/*
+ * Translate a query to an ElasticSearch search request.
+ * Return the request, the index and the list of columns. queryString is only used for errors.
+ */
+func translateQuery(query *Query, queryString, after string, opts *queryOptions) (jq jmap, index string, columns []string, sErr error) {
+	query, err := query.bindOptions(opts)
+	if err == nil {
+		err = query.checkParams()
+	}
+	if err != nil {
+		sErr = SearchError{
+			Err:   err,
+			Query: queryString,
+		}
+		return
+	}
+
+	query, _ = prepareQuery(query, opts)
 
 	if query.WhereExpr != nil {
 		jq = jmap{
-			"query": jmap{
-				"query_string": jmap{
-					"query": query.WhereExpr.QueryString(),
-					//"default_operator": "AND",
-				},
-			},
+			"query": whereQuery(query.WhereExpr, !opts.noOptimizer),
+		}
+	}
+
+	if query.TimeRange != nil {
+		var where jmap
+		if jq != nil {
+			where = jq["query"].(jmap)
+		}
+
+		jq = jmap{
+			"query": addFilter(where, query.TimeRange.query(opts.timestamp())),
 		}
 	}
 
@@ -199,7 +304,12 @@ func ParseQuery(queryString, after string) (jq jmap, index string, columns []str
 		facets := jmap{}
 
 		for _, f := range query.FacetList {
-			facets[f] = jmap{"terms": jmap{"field": f}}
+			field := f
+			if ff, ok := query.FacetFields[f]; ok {
+				field = ff
+			}
+
+			facets[f] = jmap{"terms": jmap{"field": field}}
 		}
 
 		jq["aggs"] = facets
@@ -214,8 +324,35 @@ func ParseQuery(queryString, after string) (jq jmap, index string, columns []str
 		}
 	}
 
-	if len(query.SelectList) > 0 {
-		jq["_source"] = query.SelectList
+	if query.Join != nil {
+		if fields := query.joinFields(query.Alias, query.Join.Left); len(fields) > 0 {
+			jq["_source"] = fields
+		}
+	} else {
+		source, meta := splitMetaFields(query.SelectList)
+
+		if key := retrieveKey(query.Retrieve); key != "" {
+			jq[key] = retrieveFields(query, source)
+			jq["_source"] = false
+		} else if len(source) > 0 {
+			jq["_source"] = source
+		} else if len(meta) > 0 {
+			jq["_source"] = false
+		}
+
+		if meta["_version"] {
+			jq["version"] = true
+		}
+		if meta["_seq_no"] || meta["_primary_term"] {
+			jq["seq_no_primary_term"] = true
+		}
+		if meta["_score"] && len(query.OrderList) > 0 {
+			jq["track_scores"] = true
+		}
+	}
+
+	if len(query.HighlightList) > 0 {
+		jq["highlight"] = highlightQuery(query)
 	}
 
 	if len(query.OrderList) > 0 {
@@ -231,11 +368,19 @@ func ParseQuery(queryString, after string) (jq jmap, index string, columns []str
 		after = query.After
 	}
 
-	if after != "" {
+	if query.Distinct {
+		if err := distinctQuery(query, jq, after); err != nil {
+			sErr = SearchError{
+				Err:   err,
+				Query: queryString,
+			}
+			return
+		}
+	} else if after != "" {
 		after := decodeObject(after)
 		if after == nil {
 			sErr = SearchError{
-				Err:   ParseError("invalid value for AFTER"),
+				Err:   ParseError{Msg: "invalid value for AFTER"},
 				Query: queryString,
 			}
 			return
@@ -255,43 +400,195 @@ func ParseQuery(queryString, after string) (jq jmap, index string, columns []str
 	}
 
 	columns = query.SelectList
+	if len(columns) > 0 && len(query.HighlightList) > 0 {
+		columns = append([]string{}, query.SelectList...)
+		for _, f := range query.HighlightList {
+			columns = append(columns, f+".highlight")
+		}
+	}
 	return
 }
 
-func (es *ElseSearch) Search(queryString, after, nilValue, index string, returnType ReturnType) (jmap, error) {
-	var jq jmap
-	var columns []string
+// Fields from the hit envelope that can be used in the select list
+var metaFields = map[string]bool{
+	"_id":           true,
+	"_index":        true,
+	"_score":        true,
+	"_version":      true,
+	"_routing":      true,
+	"_seq_no":       true,
+	"_primary_term": true,
+}
 
-	if strings.HasPrefix(queryString, "{") { // ES JSON query
-		jj, err := simplejson.LoadString(queryString)
-		if err != nil {
-			return nil, SearchError{
-				Err:   err,
-				Query: queryString,
+/*
+ * Split the select list in _source fields and meta fields
+ */
+func splitMetaFields(fields []string) (source []string, meta map[string]bool) {
+	meta = map[string]bool{}
+
+	for _, f := range fields {
+		if metaFields[f] {
+			meta[f] = true
+		} else {
+			source = append(source, f)
+		}
+	}
+
+	return
+}
+
+/*
+ * Return the name of the search request section for the FIELDS, DOCVALUES and STORED retrieval modes
+ * (or an empty string if the documents should be retrieved from _source)
+ */
+func retrieveKey(k Keyword) string {
+	switch k {
+	case FIELDS:
+		return "fields"
+	case DOCVALUES:
+		return "docvalue_fields"
+	case STORED:
+		return "stored_fields"
+	}
+
+	return ""
+}
+
+/*
+ * Return the list of fields to retrieve, as names or {"field": name, options...} objects
+ */
+func retrieveFields(query *Query, fields []string) jarr {
+	if len(query.SelectList) == 0 {
+		return jarr{"*"}
+	}
+
+	list := make(jarr, 0, len(fields))
+
+	for _, f := range fields {
+		if opts, ok := query.FieldOptions[f]; ok {
+			field := jmap{"field": f}
+			for _, o := range opts {
+				field[o.Name] = o.Value
 			}
+
+			list = append(list, field)
+		} else {
+			list = append(list, f)
 		}
+	}
 
-		jq = jj.MustMap()
-	} else {
-		var err error
+	return list
+}
 
-		jq, index, columns, err = ParseQuery(queryString, after)
-		if err != nil {
-			return nil, err
+/*
+ * Return a document from the hit "fields" section, where all values are lists (single values are unwrapped)
+ */
+func fieldsDocument(fields jmap) jmap {
+	doc := jmap{}
+
+	for k, v := range fields {
+		if aa, ok := v.(jarr); ok && len(aa) == 1 {
+			doc[k] = aa[0]
+		} else {
+			doc[k] = v
 		}
 	}
 
-	if Debug {
-		log.Println("SEARCH", index, simplejson.MustDumpString(jq))
+	return doc
+}
+
+/*
+ * Return the "highlight" section of the search request.
+ * The pre and post options are converted to pre_tags and post_tags, other options are passed as they are.
+ */
+func highlightQuery(query *Query) jmap {
+	fields := jmap{}
+	for _, f := range query.HighlightList {
+		fields[f] = jmap{}
 	}
 
-	if strings.HasPrefix(index, "_") {
-		return nil, SearchError{
-			Err:   ParseError("invalid index name"),
-			Query: queryString,
+	hl := jmap{"fields": fields}
+
+	for _, o := range query.HighlightOptions {
+		switch o.Name {
+		case "pre":
+			hl["pre_tags"] = jarr{o.Value}
+		case "post":
+			hl["post_tags"] = jarr{o.Value}
+		default:
+			hl[o.Name] = o.Value
+		}
+	}
+
+	return hl
+}
+
+/*
+ * Return the document for a search hit: the _source (or the hit fields for FIELDS, DOCVALUES and STORED)
+ * plus any additional fields requested by the query.
+ * Meta fields in the select list (_id, _index, etc.) are copied from the hit.
+ * Highlights are returned as a "highlights" object for the Data return type
+ * or as "field.highlight" columns (with fragments separated by " ... ") otherwise.
+ */
+func hitDocument(hit jmap, query *Query, returnType ReturnType) jmap {
+	doc, _ := hit["_source"].(jmap)
+	if query == nil {
+		return doc
+	}
+
+	if retrieveKey(query.Retrieve) != "" {
+		fields, _ := hit["fields"].(jmap)
+		doc = fieldsDocument(fields)
+	}
+
+	for _, f := range query.SelectList {
+		if metaFields[f] {
+			if doc == nil {
+				doc = jmap{}
+			}
+
+			doc[f] = hit[f]
+		}
+	}
+
+	if len(query.HighlightList) > 0 {
+		if doc == nil {
+			doc = jmap{}
+		}
+
+		hl, _ := hit["highlight"].(jmap)
+
+		if returnType == Data {
+			doc["highlights"] = hl
+		} else {
+			for _, f := range query.HighlightList {
+				var fragments []string
+				if aa, ok := hl[f].(jarr); ok {
+					for _, v := range aa {
+						fragments = append(fragments, stringify(v, ""))
+					}
+				}
+
+				if fragments != nil {
+					doc[f+".highlight"] = strings.Join(fragments, " ... ")
+				} else {
+					doc[f+".highlight"] = nil
+				}
+			}
 		}
 	}
 
+	return doc
+}
+
+func hitsTotal(hits jmap) int {
+	return int(hits["total"].(float64))
+}
+
+/*
+ * Send a search request and return the full response
+ */
+func (es *ElseSearch) search(index string, jq jmap) (jmap, error) {
 	res, err := es.client.SendRequest(es.client.Path(index+"/_search"), httpclient.JsonBody(jq))
 	defer res.Close()
 
@@ -309,138 +606,367 @@ func (es *ElseSearch) Search(queryString, after, nilValue, index string, returnT
 		}
 	}
 
-	full := res.Json().MustMap()
+	return res.Json().MustMap(), nil
+}
+
+/*
+ * Send a request to ElasticSearch and return the decoded JSON response (params and body can be nil)
+ */
+func (es *ElseSearch) request(method, path string, params map[string]interface{}, body interface{}) (jobj, error) {
+	var options []httpclient.RequestOption
+	var query string
+
+	if body != nil {
+		options = append(options, httpclient.JsonBody(body))
+		query = simplejson.MustDumpString(body)
+	}
+
+	return es.send(method, path, params, query, options...)
+}
+
+/*
+ * Send a request with a body that is not JSON (i.e. the NDJSON body of a bulk request).
+ * The body is not included in the error.
+ */
+func (es *ElseSearch) rawRequest(method, path string, params map[string]interface{}, contentType, body string) (jobj, error) {
+	return es.send(method, path, params, "",
+		httpclient.Body(strings.NewReader(body)),
+		httpclient.Header(map[string]string{"Content-Type": contentType}))
+}
+
+/*
+ * Send a request and return the JSON response (query is the body text for the error, if any)
+ */
+func (es *ElseSearch) send(method, path string, params map[string]interface{}, query string, body ...httpclient.RequestOption) (jobj, error) {
+	options := []httpclient.RequestOption{httpclient.Method(method), es.client.Path(path)}
+	if params != nil {
+		options = append(options, httpclient.Params(params))
+	}
+	options = append(options, body...)
+
+	res, err := es.client.SendRequest(options...)
+	defer res.Close()
+
+	if err == nil {
+		err = res.ResponseError()
+	}
+	if err != nil {
+		request := method + " " + path
+		if query != "" {
+			request += " " + query
+		}
+
+		return nil, SearchError{
+			Err:   err,
+			Query: request,
+		}
+	}
+
+	return res.Json().Data(), nil
+}
+
+func (es *ElseSearch) Search(queryString, after, nilValue, index string, returnType ReturnType, options ...QueryOption) (jmap, error) {
+	if strings.HasPrefix(queryString, "{") { // ES JSON query
+		jj, err := simplejson.LoadString(queryString)
+		if err != nil {
+			return nil, SearchError{
+				Err:   err,
+				Query: queryString,
+			}
+		}
+
+		return es.execute(nil, jj.MustMap(), index, nil, queryString, nilValue, returnType)
+	}
+
+	opts := getQueryOptions(options)
+
+	if opts.validate {
+		diagnostics, err := es.Validate(queryString, options...)
+		if err != nil {
+			return nil, err
+		}
+		if err := validationError(queryString, diagnostics); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.keywordFields && opts.mapping == nil {
+		mapping, err := es.queryMapping(queryString)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.mapping = mapping
+	}
+
+	query, jq, index, columns, err := parseQuery(queryString, after, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.isCommand() {
+		return es.command(query, queryString, nilValue, returnType, opts)
+	}
+
+	if query.Explain {
+		return es.explain(query, jq, index, columns, queryString, nilValue, returnType, opts)
+	}
+
+	if query.Target != "" {
+		return es.selectInto(query, jq, queryString, nilValue, returnType, opts)
+	}
+
+	return es.execute(query, jq, index, columns, queryString, nilValue, returnType)
+}
+
+/*
+ * Execute a query created with the Builder (or a modified parsed query)
+ */
+func (es *ElseSearch) SearchQuery(query *Query, after, nilValue string, returnType ReturnType, options ...QueryOption) (jmap, error) {
+	queryString := Format(query)
+	opts := getQueryOptions(options)
+
+	if query.isCommand() {
+		return es.command(query, queryString, nilValue, returnType, opts)
+	}
+
+	if opts.validate && query.Join == nil {
+		mapping, err := es.Mapping(query.Index)
+		if err != nil {
+			return nil, err
+		}
+		if err := validationError(queryString, mapping.Validate(query)); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.keywordFields && opts.mapping == nil && query.Join == nil {
+		mapping, err := es.Mapping(query.Index)
+		if err != nil {
+			return nil, err
+		}
+
+		opts.mapping = mapping
+	}
+
+	jq, index, columns, err := translateQuery(query, queryString, after, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Explain {
+		return es.explain(query, jq, index, columns, queryString, nilValue, returnType, opts)
+	}
+
+	if query.Target != "" {
+		return es.selectInto(query, jq, queryString, nilValue, returnType, opts)
+	}
+
+	return es.execute(query, jq, index, columns, queryString, nilValue, returnType)
+}
+
+/*
+ * Check that the index is not a special endpoint (i.e. _cat)
+ */
+func checkIndex(index, queryString string) error {
+	if strings.HasPrefix(index, "_") {
+		return SearchError{
+			Err:   ParseError{Msg: "invalid index name"},
+			Query: queryString,
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Send the search request and convert the result according to returnType
+ */
+func (es *ElseSearch) execute(query *Query, jq jmap, index string, columns []string, queryString, nilValue string, returnType ReturnType) (jmap, error) {
+	if Debug {
+		log.Println("SEARCH", index, simplejson.MustDumpString(jq))
+	}
+
+	if err := checkIndex(index, queryString); err != nil {
+		return nil, err
+	}
+
+	if query != nil && query.Join != nil {
+		return es.join(query, jq, index, columns, nilValue, returnType)
+	}
+
+	full, err := es.search(index, jq)
+	if err != nil {
+		return nil, err
+	}
+
+	if query != nil && query.Distinct && len(query.SelectList) > 1 && returnType != Full {
+		data, err := distinctResult(full, columns, nilValue, returnType)
+		if err != nil {
+			return nil, SearchError{
+				Err:   err,
+				Query: simplejson.MustDumpString(jq),
+			}
+		}
+		return data, nil
+	}
 
 	switch returnType {
 	case Full:
 		return full, nil
 
-	case Data:
-		data := jmap{}
-		if aggs, ok := full["aggregations"]; ok {
-			data["facets"] = aggs
-		}
-
+	case Data, List, StringList:
 		hits := full["hits"].(jmap)
 		list := hits["hits"].(jarr)
-		rows := make(jarr, 0, len(list))
+		docs := make([]jmap, 0, len(list))
 		var last jobj
+
 		for _, r := range list {
-			rows = append(rows, r.(jmap)["_source"])
+			docs = append(docs, hitDocument(r.(jmap), query, returnType))
 			last = r.(jmap)["sort"]
 		}
-		data["rows"] = rows
-		data["total"] = int(hits["total"].(float64))
-		if last != nil {
-			data["last"] = encodeObject(last)
+
+		if returnType != Data && len(columns) == 0 && len(docs) > 0 {
+			columns = sourceColumns(docs[0], "") // assume the first row has all the names
 		}
-		return data, nil
 
-	case List, StringList:
-		data := jmap{}
-		if aggs, ok := full["aggregations"]; ok {
-			data["facets"] = aggs
+		data, err := searchResult(full["aggregations"], docs, columns, hitsTotal(hits), last, nilValue, returnType)
+		if err != nil {
+			return nil, SearchError{
+				Err:   err,
+				Query: simplejson.MustDumpString(jq),
+			}
 		}
+		return data, nil
+	}
 
-		hits := full["hits"].(jmap)
-		list := hits["hits"].(jarr)
-		rows := make(jarr, 0, len(list))
-		var last jobj
+	return nil, nil
+}
 
-		if len(columns) == 0 && len(list) > 0 {
-			m := list[0].(jmap)["_source"].(jmap) // assume the first row has all the names
-			for k, _ := range m {
-				columns = append(columns, k)
-			}
-			sort.Strings(columns)
+/*
+ * Return the (sorted) list of names in a document, with an optional prefix
+ */
+func sourceColumns(m jmap, prefix string) (columns []string) {
+	for k, _ := range m {
+		columns = append(columns, prefix+k)
+	}
+
+	sort.Strings(columns)
+	return
+}
+
+/*
+ * Build the result for the Data, List and StringList return types
+ */
+func searchResult(facets jobj, docs []jmap, columns []string, total int, last jobj, nilValue string, returnType ReturnType) (jmap, error) {
+	rows, err := resultRows(docs, columns, nilValue, returnType)
+	if err != nil {
+		return nil, err
+	}
+
+	data := jmap{}
+	if facets != nil {
+		data["facets"] = facets
+	}
+	if returnType != Data {
+		data["columns"] = columns
+	}
+	data["rows"] = rows
+	data["total"] = total
+	if last != nil {
+		data["last"] = encodeObject(last)
+	}
+	return data, nil
+}
+
+/*
+ * Convert the documents returned by a search into result rows.
+ * For the List and StringList return types each document becomes one row (or more for nested lists)
+ * with a value for each column.
+ */
+func resultRows(docs []jmap, columns []string, nilValue string, returnType ReturnType) (jarr, error) {
+	rows := make(jarr, 0, len(docs))
+
+	if returnType == Data {
+		for _, m := range docs {
+			rows = append(rows, m)
 		}
 
-		for _, r := range list {
-			m := r.(jmap)["_source"].(jmap)
-			last = r.(jmap)["sort"]
+		return rows, nil
+	}
 
-			a := make(jarr, len(columns))
+	for _, m := range docs {
+		a := make(jarr, len(columns))
 
-			var l []struct {
-				pos int
-				arr jarr
-			}
+		var l []struct {
+			pos int
+			arr jarr
+		}
 
-			nested := ""
+		nested := ""
 
-			for i, k := range columns {
-				res := getpath(m, k)
-				if aa, ok := res.(jarr); ok {
-					if returnType == StringList {
-						a[i] = nilValue
-					} else {
-						a[i] = nil
-					}
-					switch len(aa) {
-					case 0:
-						continue
-					case 1:
-						res = aa[0]
-						if returnType == StringList {
-							res = stringify(aa[0], nilValue)
-						}
-						a[i] = res
-						continue
-					default:
-						if nested != "" && parent(k) != nested {
-							return nil, SearchError{
-								Err:   fmt.Errorf("too many nested lists in result"),
-								Query: simplejson.MustDumpString(jq),
-							}
-						}
-					}
-					nested = parent(k)
-					l = append(l, struct {
-						pos int
-						arr jarr
-					}{
-						pos: i,
-						arr: aa,
-					})
+		for i, k := range columns {
+			res := getvalue(m, k)
+			if aa, ok := res.(jarr); ok {
+				if returnType == StringList {
+					a[i] = nilValue
 				} else {
+					a[i] = nil
+				}
+				switch len(aa) {
+				case 0:
+					continue
+				case 1:
+					res = aa[0]
 					if returnType == StringList {
-						res = stringify(res, nilValue)
+						res = stringify(aa[0], nilValue)
 					}
 					a[i] = res
+					continue
+				default:
+					if nested != "" && parent(k) != nested {
+						return nil, fmt.Errorf("too many nested lists in result")
+					}
 				}
+				nested = parent(k)
+				l = append(l, struct {
+					pos int
+					arr jarr
+				}{
+					pos: i,
+					arr: aa,
+				})
+			} else {
+				if returnType == StringList {
+					res = stringify(res, nilValue)
+				}
+				a[i] = res
 			}
+		}
 
-			if nested != "" { // we have nested fields
-				ll := len(l[0].arr)
+		if nested != "" { // we have nested fields
+			ll := len(l[0].arr)
 
-				for i := 0; i < ll; i++ {
-					ele := make(jarr, len(a))
-					copy(ele, a)
+			for i := 0; i < ll; i++ {
+				ele := make(jarr, len(a))
+				copy(ele, a)
 
-					for _, aa := range l {
-						if i < len(aa.arr) {
-							if returnType == StringList {
-								ele[aa.pos] = stringify(aa.arr[i], nilValue)
-							} else {
-								ele[aa.pos] = aa.arr[i]
-							}
+				for _, aa := range l {
+					if i < len(aa.arr) {
+						if returnType == StringList {
+							ele[aa.pos] = stringify(aa.arr[i], nilValue)
+						} else {
+							ele[aa.pos] = aa.arr[i]
 						}
 					}
-
-					rows = append(rows, ele)
 				}
-			} else {
-				rows = append(rows, a)
+
+				rows = append(rows, ele)
 			}
+		} else {
+			rows = append(rows, a)
 		}
-		data["columns"] = columns
-		data["rows"] = rows
-		data["total"] = int(hits["total"].(float64))
-		if last != nil {
-			data["last"] = encodeObject(last)
-		}
-		return data, nil
 	}
 
-	return nil, nil
+	return rows, nil
 }
@@ -0,0 +1,179 @@
+package elseql
+
+import (
+	"fmt"
+	"time"
+)
+
+/*
+ * Long running operations (UPDATE, DELETE) are started as ElasticSearch tasks (wait_for_completion=false)
+ * and polled with the tasks API until they complete.
+ */
+
+var (
+	// Interval between two requests for the status of a running task
+	TaskPollInterval = time.Second
+
+	// Maximum time to wait for a task to complete (0 to wait forever), unless set with WithTaskTimeout
+	TaskTimeout = time.Duration(0)
+)
+
+/*
+ * Call progress with the status of the running tasks (i.e. total, updated, deleted, batches)
+ */
+func WithProgress(progress func(status map[string]interface{})) QueryOption {
+	return func(o *queryOptions) {
+		o.progress = progress
+	}
+}
+
+/*
+ * Cancel a running task (and return an error) if it doesn't complete within timeout (0 to wait forever)
+ */
+func WithTaskTimeout(timeout time.Duration) QueryOption {
+	return func(o *queryOptions) {
+		o.taskTimeout = &timeout
+	}
+}
+
+/*
+ * Return the timeout for running tasks (WithTaskTimeout or TaskTimeout)
+ */
+func (o *queryOptions) timeout() time.Duration {
+	if o.taskTimeout != nil {
+		return *o.taskTimeout
+	}
+
+	return TaskTimeout
+}
+
+/*
+ * Return the message for an error object ("type: reason") or value
+ */
+func errorReason(e jobj) string {
+	if em, ok := e.(jmap); ok {
+		return fmt.Sprintf("%v: %v", em["type"], em["reason"])
+	}
+
+	return stringify(e, "")
+}
+
+/*
+ * Return the status of a running task, or the response of a completed task
+ */
+func taskResult(res jmap) (completed bool, status jmap, err error) {
+	if task, ok := res["task"].(jmap); ok {
+		status, _ = task["status"].(jmap)
+	}
+
+	if completed, _ = res["completed"].(bool); !completed {
+		return
+	}
+
+	if e, ok := res["error"]; ok {
+		return true, nil, fmt.Errorf("%v", errorReason(e))
+	}
+
+	status, _ = res["response"].(jmap)
+
+	// by query requests complete (and are not retried) when some documents cannot be updated or deleted
+	if failures, _ := status["failures"].(jarr); len(failures) > 0 {
+		err = fmt.Errorf("%v failures: %v", len(failures), failureReason(failures[0]))
+	}
+
+	return
+}
+
+/*
+ * Return the message for an item of the failures list of a by query response
+ */
+func failureReason(f jobj) string {
+	fm, _ := f.(jmap)
+
+	if cause, ok := fm["cause"]; ok { // bulk failure
+		return fmt.Sprintf("%v: %v", fm["id"], errorReason(cause))
+	}
+	if reason, ok := fm["reason"]; ok { // search failure
+		return errorReason(reason)
+	}
+
+	return errorReason(f)
+}
+
+/*
+ * Wait for a task to complete and return the task response.
+ * If progress is not nil it's called with the task status after each poll.
+ * If timeout is not 0 and the task doesn't complete in time the task is cancelled and an error is returned.
+ */
+func (es *ElseSearch) WaitTask(task string, timeout time.Duration, progress func(status map[string]interface{})) (map[string]interface{}, error) {
+	path := "_tasks/" + task
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for {
+		res, err := es.request("GET", path, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		m, _ := res.(jmap)
+
+		completed, status, err := taskResult(m)
+		if err != nil {
+			return nil, SearchError{
+				Err:   err,
+				Query: "GET " + path,
+			}
+		}
+		if completed {
+			return status, nil
+		}
+
+		if progress != nil && status != nil {
+			progress(status)
+		}
+
+		if !deadline.IsZero() && time.Now().Add(TaskPollInterval).After(deadline) {
+			return nil, es.cancelTask(task, timeout)
+		}
+
+		time.Sleep(TaskPollInterval)
+	}
+}
+
+/*
+ * Cancel a task that didn't complete within timeout and return the error for the caller
+ */
+func (es *ElseSearch) cancelTask(task string, timeout time.Duration) error {
+	path := "_tasks/" + task + "/_cancel"
+
+	if _, err := es.request("POST", path, nil, nil); err != nil {
+		return SearchError{
+			Err:   fmt.Errorf("task not completed after %v and not cancelled: %v", timeout, err),
+			Query: "POST " + path,
+		}
+	}
+
+	return SearchError{
+		Err:   fmt.Errorf("task not completed after %v (cancelled)", timeout),
+		Query: "POST " + path,
+	}
+}
+
+/*
+ * Return the id of the task started by a request with wait_for_completion=false
+ */
+func taskId(res jobj, query string) (string, error) {
+	m, _ := res.(jmap)
+	if task, ok := m["task"].(string); ok && task != "" {
+		return task, nil
+	}
+
+	return "", SearchError{
+		Err:   fmt.Errorf("missing task in response"),
+		Query: query,
+	}
+}
@@ -0,0 +1,66 @@
+package elseql
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobs/simplejson"
+)
+
+func TestTaskResult(t *testing.T) {
+	tests := []struct {
+		response  string
+		completed bool
+		status    string
+		err       string
+	}{
+		{`{"completed": false, "task": {"status": {"total": 10, "updated": 4}}}`, false, `{"total":10,"updated":4}`, ""},
+		{`{"completed": true, "task": {"status": {}}, "response": {"total": 10, "updated": 10}}`, true, `{"total":10,"updated":10}`, ""},
+		{`{"completed": true, "error": {"type": "search_phase_execution_exception", "reason": "all shards failed"}}`, true, "", "search_phase_execution_exception: all shards failed"},
+		{`{"completed": true, "response": {"total": 2, "updated": 1, "failures": []}}`, true, `{"failures":[],"total":2,"updated":1}`, ""},
+		{
+			`{"completed": true, "response": {"total": 2, "updated": 1, "failures": [{"index": "idx", "id": "1", "cause": {"type": "mapper_parsing_exception", "reason": "failed to parse"}}]}}`,
+			true, "", "1 failures: 1: mapper_parsing_exception: failed to parse",
+		},
+		{
+			`{"completed": true, "response": {"total": 2, "failures": [{"shard": 0, "reason": {"type": "illegal_argument_exception", "reason": "bad script"}}]}}`,
+			true, "", "1 failures: illegal_argument_exception: bad script",
+		},
+	}
+
+	for _, test := range tests {
+		jj, _ := simplejson.LoadString(test.response)
+
+		completed, status, err := taskResult(jj.MustMap())
+		if completed != test.completed {
+			t.Errorf("%v: expected completed %v", test.response, test.completed)
+		}
+
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v: expected error %q, got %v", test.response, test.err, err)
+			}
+		} else if s := strings.TrimSpace(simplejson.MustDumpString(status)); err != nil || s != test.status {
+			t.Errorf("%v: expected %v, got %v (%v)", test.response, test.status, s, err)
+		}
+	}
+
+	saved := TaskTimeout
+	defer func() { TaskTimeout = saved }()
+
+	TaskTimeout = time.Minute
+	if timeout := getQueryOptions(nil).timeout(); timeout != time.Minute {
+		t.Errorf("expected default timeout, got %v", timeout)
+	}
+	if timeout := getQueryOptions([]QueryOption{WithTaskTimeout(0)}).timeout(); timeout != 0 {
+		t.Errorf("expected no timeout, got %v", timeout)
+	}
+
+	if _, err := taskId(jmap{"task": "node:1"}, ""); err != nil {
+		t.Error(err)
+	}
+	if _, err := taskId(jmap{"took": 1.0}, ""); err == nil {
+		t.Error("expected error for missing task")
+	}
+}
@@ -0,0 +1,268 @@
+package elseql
+
+import (
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+/*
+ * Time bounded queries:
+ *
+ *   SELECT ... FROM index WHERE expr DURING LAST 15m
+ *   SELECT ... FROM index WHERE expr SINCE '2024-01-01' UNTIL now-1d/d WITH (time_zone='+01:00', format='yyyy-MM-dd')
+ *
+ * The time range is added to the query as a range filter on the timestamp field (SINCE is inclusive, UNTIL is exclusive).
+ * The timestamp field is TimestampField, unless changed with WithTimestampField or with the field option (WITH (field='ts')).
+ * Other options (i.e. time_zone and format) are passed to the range query.
+ *
+ * Values can be date-math expressions (now, now-1h, now-1d/d), also accepted in WHERE comparisons.
+ */
+
+var (
+	// Default timestamp field for DURING, SINCE and UNTIL
+	TimestampField = "@timestamp"
+)
+
+// date-math time units
+const dateUnits = "yMwdhHms"
+
+/*
+ * A date-math expression (i.e. now-1h/h), used as is in the search request
+ */
+type DateMath string
+
+/*
+ * The time range of a query: DURING LAST duration or SINCE value UNTIL value (both optional)
+ */
+type TimeRange struct {
+	Last    string      // DURING LAST duration (i.e. 15m)
+	Since   interface{} // SINCE value (inclusive)
+	Until   interface{} // UNTIL value (exclusive)
+	Options []NameValue // WITH (field, time_zone, format...)
+}
+
+/*
+ * Set the timestamp field for DURING, SINCE and UNTIL (the default is TimestampField)
+ */
+func WithTimestampField(field string) QueryOption {
+	return func(o *queryOptions) {
+		o.timestampField = field
+	}
+}
+
+/*
+ * Parse a date-math time unit
+ */
+func (p *ElseParser) parseDateUnit() (string, error) {
+	if p.nextToken() == scanner.Ident && len(p.lastText) == 1 && strings.Contains(dateUnits, p.lastText) {
+		unit := p.lastText
+		p.lastText = ""
+		return unit, nil
+	}
+
+	return "", p.parseError("time unit (" + strings.Join(strings.Split(dateUnits, ""), ", ") + ")")
+}
+
+/*
+ * Parse a duration: number followed by a time unit (i.e. 15m)
+ */
+func (p *ElseParser) parseDuration() (string, error) {
+	if p.nextToken() != scanner.Int {
+		return "", p.parseError("duration")
+	}
+
+	n, err := p.parseInteger()
+	if err != nil {
+		return "", err
+	}
+
+	unit, err := p.parseDateUnit()
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.Itoa(n) + unit, nil
+}
+
+/*
+ * Parse a date-math expression: now {(+|-) duration | / unit} (the current token is now)
+ */
+func (p *ElseParser) parseDateMath() (DateMath, error) {
+	p.lastText = ""
+	s := "now"
+
+	for {
+		switch op := p.nextToken(); op {
+		case '+', '-':
+			p.lastText = ""
+
+			d, err := p.parseDuration()
+			if err != nil {
+				return "", err
+			}
+
+			s += string(op) + d
+
+		case '/':
+			p.lastText = ""
+
+			unit, err := p.parseDateUnit()
+			if err != nil {
+				return "", err
+			}
+
+			s += "/" + unit
+
+		default:
+			return DateMath(s), nil
+		}
+	}
+}
+
+/*
+ * Parse DURING LAST duration or SINCE value UNTIL value, with optional WITH (options)
+ */
+func (p *ElseParser) parseTimeRange() (err error) {
+	var tr TimeRange
+
+	if match, _ := p.parseKeyword(DURING, true); match {
+		if err = p.parseRequired(LAST); err != nil {
+			return
+		}
+		if tr.Last, err = p.parseDuration(); err != nil {
+			return
+		}
+	} else {
+		if match, _ := p.parseKeyword(SINCE, true); match {
+			if tr.Since, err = p.parseValue(); err != nil {
+				return
+			}
+		}
+		if match, _ := p.parseKeyword(UNTIL, true); match {
+			if tr.Until, err = p.parseValue(); err != nil {
+				return
+			}
+		}
+
+		if tr.Since == nil && tr.Until == nil {
+			return
+		}
+	}
+
+	if match, _ := p.parseKeyword(WITH, true); match {
+		if tr.Options, err = p.parseOptions(); err != nil {
+			return
+		}
+	}
+
+	p.query.TimeRange = &tr
+	return
+}
+
+/*
+ * Return the text for a time range clause (or "" if tr is nil)
+ */
+func formatTimeRange(tr *TimeRange) string {
+	if tr == nil {
+		return ""
+	}
+
+	var parts []string
+
+	if tr.Last != "" {
+		parts = append(parts, DURING.String()+" "+LAST.String()+" "+tr.Last)
+	}
+	if tr.Since != nil {
+		parts = append(parts, SINCE.String()+" "+formatValue(tr.Since))
+	}
+	if tr.Until != nil {
+		parts = append(parts, UNTIL.String()+" "+formatValue(tr.Until))
+	}
+	if len(tr.Options) > 0 {
+		parts = append(parts, WITH.String()+" "+formatOptions(tr.Options))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+/*
+ * Replace the parameter placeholders in SINCE and UNTIL
+ */
+func (tr *TimeRange) bindParams(bind func(Param) (interface{}, error)) (err error) {
+	if tr == nil {
+		return nil
+	}
+
+	for _, v := range []*interface{}{&tr.Since, &tr.Until} {
+		param, ok := (*v).(Param)
+		if !ok {
+			continue
+		}
+
+		if *v, err = bind(param); err != nil {
+			return
+		}
+		if _, ok := (*v).([]interface{}); ok {
+			return ParseError{Msg: "invalid list value for parameter " + param.String()}
+		}
+	}
+
+	return nil
+}
+
+/*
+ * Return the range query for the time range (field is the default timestamp field)
+ */
+func (tr *TimeRange) query(field string) jmap {
+	r := jmap{}
+
+	if tr.Last != "" {
+		r["gte"] = "now-" + tr.Last
+	}
+	if tr.Since != nil {
+		r["gte"] = tr.Since
+	}
+	if tr.Until != nil {
+		r["lt"] = tr.Until
+	}
+
+	for _, o := range tr.Options {
+		if o.Name == "field" {
+			field = stringify(o.Value, "")
+		} else {
+			r[o.Name] = o.Value
+		}
+	}
+
+	return jmap{"range": jmap{field: r}}
+}
+
+/*
+ * Add a filter to a query (a bool query is extended, other queries are wrapped in a bool query)
+ */
+func addFilter(query, filter jmap) jmap {
+	if query == nil {
+		return jmap{"bool": jmap{"filter": jarr{filter}}}
+	}
+
+	if bq, ok := query["bool"].(jmap); ok && len(query) == 1 {
+		if list, ok := bq["filter"].(jarr); ok {
+			bq["filter"] = append(list, filter)
+			return query
+		}
+	}
+
+	return jmap{"bool": jmap{"must": query, "filter": jarr{filter}}}
+}
+
+/*
+ * Return the timestamp field for the query options
+ */
+func (o *queryOptions) timestamp() string {
+	if o.timestampField != "" {
+		return o.timestampField
+	}
+
+	return TimestampField
+}
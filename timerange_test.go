@@ -0,0 +1,127 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDateMath(t *testing.T) {
+	tests := []struct {
+		statement string
+		value     interface{}
+	}{
+		{"SELECT * FROM idx WHERE ts > now", DateMath("now")},
+		{"SELECT * FROM idx WHERE ts > now-1h/h", DateMath("now-1h/h")},
+		{"SELECT * FROM idx WHERE ts >= NOW + 1d - 30m", DateMath("now+1d-30m")},
+		{"SELECT * FROM idx WHERE ts < now/M", DateMath("now/M")},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		nv := parser.Query().WhereExpr.operands[0].(NameValue)
+		if nv.Value != test.value {
+			t.Errorf("%v: expected %v, got %#v", test.statement, test.value, nv.Value)
+		}
+	}
+
+	for _, statement := range []string{
+		"SELECT * FROM idx WHERE ts > now-1",
+		"SELECT * FROM idx WHERE ts > now-1x",
+		"SELECT * FROM idx WHERE ts > now/",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	tests := []struct {
+		statement string
+		format    string
+	}{
+		{
+			"SELECT * FROM logs WHERE level = 'error' DURING LAST 15m",
+			"SELECT *\nFROM logs\nWHERE level = \"error\"\nDURING LAST 15m",
+		},
+		{
+			"SELECT * FROM logs SINCE '2024-01-01' UNTIL now-1d/d WITH (time_zone='+01:00') LIMIT 10",
+			"SELECT *\nFROM logs\nSINCE \"2024-01-01\" UNTIL now-1d/d WITH (time_zone=\"+01:00\")\nLIMIT 10",
+		},
+		{
+			"DELETE FROM logs UNTIL now-30d",
+			"DELETE FROM logs\nUNTIL now-30d",
+		},
+		{
+			"SELECT last, since FROM logs WHERE during = 1 AND until > 2 DURING LAST 1h",
+			"SELECT last, since\nFROM logs\nWHERE during = 1\n  AND until > 2\nDURING LAST 1h",
+		},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		if f := Format(parser.Query()); f != test.format {
+			t.Errorf("%v: expected %q, got %q", test.statement, test.format, f)
+		}
+	}
+
+	for _, statement := range []string{
+		"SELECT * FROM logs DURING 15m",
+		"SELECT * FROM logs DURING LAST 15",
+		"SELECT * FROM logs DURING LAST 15m SINCE now-1d",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+}
+
+func TestTimeRangeQuery(t *testing.T) {
+	jq, _, _, err := ParseQuery("SELECT * FROM logs WHERE status = 500 SINCE :start UNTIL now WITH (format='yyyy-MM-dd', time_zone='UTC')", "",
+		WithParams(map[string]interface{}{"start": "2024-01-01"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := jmap{"bool": jmap{"filter": jarr{
+		jmap{"term": jmap{"status": 500}},
+		jmap{"range": jmap{"@timestamp": jmap{"gte": "2024-01-01", "lt": DateMath("now"), "format": "yyyy-MM-dd", "time_zone": "UTC"}}},
+	}}}
+
+	if !reflect.DeepEqual(jq["query"], expected) {
+		t.Errorf("unexpected query %v", jq["query"])
+	}
+
+	jq, _, _, err = ParseQuery("SELECT * FROM logs DURING LAST 1h", "", WithTimestampField("ts"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = jmap{"bool": jmap{"filter": jarr{
+		jmap{"range": jmap{"ts": jmap{"gte": "now-1h"}}},
+	}}}
+
+	if !reflect.DeepEqual(jq["query"], expected) {
+		t.Errorf("unexpected query %v", jq["query"])
+	}
+
+	tr := TimeRange{Last: "1d", Options: []NameValue{{"field", "created"}}}
+	if q := tr.query(TimestampField); !reflect.DeepEqual(q, jmap{"range": jmap{"created": jmap{"gte": "now-1d"}}}) {
+		t.Errorf("unexpected range %v", q)
+	}
+
+	if q := addFilter(jmap{"query_string": jmap{"query": "a"}}, jmap{"exists": jmap{"field": "b"}}); !reflect.DeepEqual(q, jmap{"bool": jmap{
+		"must":   jmap{"query_string": jmap{"query": "a"}},
+		"filter": jarr{jmap{"exists": jmap{"field": "b"}}},
+	}}) {
+		t.Errorf("unexpected query %v", q)
+	}
+}
@@ -0,0 +1,459 @@
+package elseql
+
+import (
+	"strconv"
+	"strings"
+	"text/scanner"
+)
+
+/*
+ * UPDATE and DELETE statements:
+ *
+ *   UPDATE index SET status = 'closed', n = n + 1 WHERE expr LIMIT n
+ *   DELETE FROM index WHERE expr LIMIT n
+ *
+ * The WHERE clause is translated as for SELECT and the statements are executed with _update_by_query
+ * and _delete_by_query (LIMIT sets max_docs). The SET assignments are compiled to a painless script,
+ * with the literal values passed as script parameters.
+ *
+ * The requests are started as tasks and polled until they complete (see WaitTask).
+ */
+
+var (
+	byQueryColumns = []string{"total", "updated", "deleted", "version_conflicts", "noops", "failures"}
+	dryRunColumns  = []string{"matches"}
+)
+
+/*
+ * A reference to a field of the document being updated, in a SET value
+ */
+type FieldRef string
+
+/*
+ * An arithmetic expression in a SET value (operators are +, -, * and /)
+ */
+type SetExpression struct {
+	Op          rune
+	Left, Right interface{}
+}
+
+/*
+ * Only count the documents matching the WHERE clause of UPDATE, DELETE and SELECT INTO statements (without modifying them)
+ */
+func WithDryRun() QueryOption {
+	return func(o *queryOptions) {
+		o.dryRun = true
+	}
+}
+
+func (p *ElseParser) parseUpdate() (err error) {
+	if p.query.Index, err = p.parseIndexName(); err != nil {
+		return
+	}
+	if err = p.parseRequired(SET); err != nil {
+		return
+	}
+
+	p.query.SetList, err = p.parseAssignments()
+	return
+}
+
+/*
+ * Parse a (comma separated) list of name = value assignments
+ */
+func (p *ElseParser) parseAssignments() ([]NameValue, error) {
+	var list []NameValue
+
+	for {
+		name, err := p.parseIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		if metaFields[name] {
+			return nil, ParseError{Pos: p.lastPos, Msg: "cannot SET " + name}
+		}
+
+		if _, err := p.parseToken('=', false); err != nil {
+			return nil, err
+		}
+
+		value, err := p.parseSetValue()
+		if err != nil {
+			return nil, err
+		}
+
+		list = append(list, NameValue{name, value})
+
+		if match, _ := p.parseToken(list_sep, true); !match {
+			return list, nil
+		}
+	}
+}
+
+func (p *ElseParser) parseDelete() (err error) {
+	if err = p.parseRequired(FROM); err != nil {
+		return
+	}
+
+	p.query.Index, err = p.parseIndexName()
+	return
+}
+
+/*
+ * Parse LIMIT n (the maximum number of documents to update or delete)
+ */
+func (p *ElseParser) parseMaxDocs() error {
+	if err := p.parseLimit(); err != nil {
+		return err
+	}
+	if p.query.From > 0 {
+		return ParseError{Pos: p.lastPos, Msg: "LIMIT offset is not supported with " + p.query.Command.String()}
+	}
+
+	return nil
+}
+
+/*
+ * Parse a SET value: term {(+|-) term}
+ */
+func (p *ElseParser) parseSetValue() (interface{}, error) {
+	left, err := p.parseSetTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.nextToken()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+
+		p.lastText = ""
+
+		right, err := p.parseSetTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &SetExpression{op, left, right}
+	}
+}
+
+/*
+ * Parse a SET term: factor {(*|/) factor}
+ */
+func (p *ElseParser) parseSetTerm() (interface{}, error) {
+	left, err := p.parseSetFactor()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := p.nextToken()
+		if op != '*' && op != '/' {
+			return left, nil
+		}
+
+		p.lastText = ""
+
+		right, err := p.parseSetFactor()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &SetExpression{op, left, right}
+	}
+}
+
+/*
+ * Parse a SET factor: (value), field name, null or value
+ */
+func (p *ElseParser) parseSetFactor() (interface{}, error) {
+	if match, _ := p.parseToken('(', true); match {
+		v, err := p.parseSetValue()
+		if err != nil {
+			return nil, err
+		}
+
+		return v, p.parseParen(CLOSEP)
+	}
+
+	if p.nextToken() == scanner.Ident {
+		switch strings.ToLower(p.lastText) {
+		case "true", "false":
+			return p.parseValue()
+
+		case "null":
+			p.lastText = ""
+			return nil, nil
+
+		case RAW.Lower():
+			if tok, _ := p.peekToken(); tok == '(' {
+				return p.parseRaw()
+			}
+		}
+
+		name, err := p.parseIdentifier()
+		return FieldRef(name), err
+	}
+
+	return p.parseValue()
+}
+
+/*
+ * Replace the parameter placeholders in a SET value
+ */
+func bindSetValue(v interface{}, bind func(Param) (interface{}, error)) (interface{}, error) {
+	switch vv := v.(type) {
+	case Param:
+		value, err := bind(vv)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := value.([]interface{}); ok {
+			return nil, ParseError{Msg: "invalid list value for parameter " + vv.String()}
+		}
+
+		return value, nil
+
+	case *SetExpression:
+		left, err := bindSetValue(vv.Left, bind)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := bindSetValue(vv.Right, bind)
+		if err != nil {
+			return nil, err
+		}
+
+		return &SetExpression{vv.Op, left, right}, nil
+	}
+
+	return v, nil
+}
+
+func setPrecedence(op rune) int {
+	if op == '*' || op == '/' {
+		return 2
+	}
+
+	return 1
+}
+
+/*
+ * Format a SET value (prec is the precedence of the parent operator, right is true for its right operand)
+ */
+func formatSetValue(v interface{}, prec int, right bool) string {
+	switch vv := v.(type) {
+	case nil:
+		return "null"
+
+	case FieldRef:
+		return string(vv)
+
+	case *SetExpression:
+		p := setPrecedence(vv.Op)
+		s := formatSetValue(vv.Left, p, false) + " " + string(vv.Op) + " " + formatSetValue(vv.Right, p, true)
+		if p < prec || (p == prec && right) {
+			return "(" + s + ")"
+		}
+
+		return s
+	}
+
+	return formatValue(v)
+}
+
+func formatAssignments(list []NameValue) string {
+	set := make([]string, 0, len(list))
+	for _, nv := range list {
+		set = append(set, nv.Name+" = "+formatSetValue(nv.Value, 0, false))
+	}
+
+	return strings.Join(set, ", ")
+}
+
+func formatByQuery(q *Query) string {
+	var lines []string
+
+	if q.Command == UPDATE {
+		lines = append(lines, UPDATE.String()+" "+formatIndexName(q.Index), SET.String()+" "+formatAssignments(q.SetList))
+	} else {
+		lines = append(lines, DELETE.String()+" "+FROM.String()+" "+formatIndexName(q.Index))
+	}
+
+	if q.WhereExpr != nil {
+		lines = append(lines, WHERE.String()+" "+formatTopExpression(q.WhereExpr))
+	}
+	if q.TimeRange != nil {
+		lines = append(lines, formatTimeRange(q.TimeRange))
+	}
+	if q.Size >= 0 {
+		lines = append(lines, LIMIT.String()+" "+strconv.Itoa(q.Size))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+/*
+ * Return the painless expression for a document field (ctx._source['a']['b'] for a.b)
+ */
+func sourceField(name string) string {
+	s := "ctx._source"
+	for _, part := range strings.Split(name, string(id_sep)) {
+		s += "['" + part + "']"
+	}
+
+	return s
+}
+
+/*
+ * Return the painless statements that create the missing parent objects of a dotted field (a and a.b for a.b.c)
+ */
+func sourceParents(name string) string {
+	parts := strings.Split(name, string(id_sep))
+
+	s, parent := "", "ctx._source"
+	for _, part := range parts[:len(parts)-1] {
+		s += parent + ".putIfAbsent('" + part + "', [:]); "
+		parent += "['" + part + "']"
+	}
+
+	return s
+}
+
+/*
+ * Return the painless expression for a SET value, adding literal values to params
+ */
+func painlessValue(v interface{}, params jmap) string {
+	switch vv := v.(type) {
+	case nil:
+		return "null"
+
+	case FieldRef:
+		return sourceField(string(vv))
+
+	case *SetExpression:
+		return "(" + painlessValue(vv.Left, params) + " " + string(vv.Op) + " " + painlessValue(vv.Right, params) + ")"
+	}
+
+	name := "p" + strconv.Itoa(len(params))
+	params[name] = v
+	return "params." + name
+}
+
+/*
+ * Compile the SET assignments to a painless script
+ */
+func setScript(list []NameValue) jmap {
+	params := jmap{}
+	statements := make([]string, 0, len(list))
+
+	for _, nv := range list {
+		statements = append(statements, sourceParents(nv.Name)+sourceField(nv.Name)+" = "+painlessValue(nv.Value, params)+";")
+	}
+
+	return jmap{
+		"source": strings.Join(statements, " "),
+		"lang":   "painless",
+		"params": params,
+	}
+}
+
+/*
+ * Return the result of an UPDATE, DELETE or SELECT INTO statement (the counters of the task response)
+ */
+func byQueryResult(response jmap, columns []string, nilValue string, returnType ReturnType) (jmap, error) {
+	if returnType == Full {
+		return response, nil
+	}
+
+	doc := jmap{}
+	for _, c := range columns {
+		doc[c] = response[c]
+	}
+
+	failures, _ := response["failures"].(jarr)
+	doc["failures"] = len(failures)
+
+	total, _ := response["total"].(float64)
+	return searchResult(nil, []jmap{doc}, columns, int(total), nil, nilValue, returnType)
+}
+
+/*
+ * Return the number of documents matching a query (at most limit, if not negative) for a dry run
+ */
+func (es *ElseSearch) dryRun(index string, body jmap, limit int, nilValue string, returnType ReturnType) (jmap, error) {
+	res, err := es.request("POST", index+"/_count", nil, body)
+	if err != nil {
+		return nil, err
+	}
+
+	m, _ := res.(jmap)
+	count, _ := m["count"].(float64)
+	matches := int(count)
+	if limit >= 0 && matches > limit {
+		matches = limit
+	}
+
+	if returnType == Full {
+		return jmap{"matches": matches}, nil
+	}
+
+	return searchResult(nil, []jmap{{"matches": matches}}, dryRunColumns, matches, nil, nilValue, returnType)
+}
+
+/*
+ * Execute an UPDATE or DELETE statement (or count the matching documents for a dry run)
+ */
+func (es *ElseSearch) byQuery(query *Query, queryString, nilValue string, returnType ReturnType, opts *queryOptions) (jmap, error) {
+	source := *query
+	source.Command = SELECT
+	source.Size = -1
+
+	jq, index, _, err := translateQuery(&source, queryString, "", opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkIndex(index, queryString); err != nil {
+		return nil, err
+	}
+
+	body := jmap{"query": jq["query"]}
+
+	if opts.dryRun {
+		return es.dryRun(index, body, query.Size, nilValue, returnType)
+	}
+
+	path := index + "/_delete_by_query"
+	if query.Command == UPDATE {
+		path = index + "/_update_by_query"
+		body["script"] = setScript(query.SetList)
+	}
+	if query.Size >= 0 {
+		body["max_docs"] = query.Size
+	}
+
+	params := map[string]interface{}{"wait_for_completion": "false"}
+	if opts.refresh != "" {
+		params["refresh"] = opts.refresh
+	}
+
+	res, err := es.request("POST", path, params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := taskId(res, "POST "+path)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := es.WaitTask(task, opts.timeout(), opts.progress)
+	if err != nil {
+		return nil, err
+	}
+
+	return byQueryResult(response, byQueryColumns, nilValue, returnType)
+}
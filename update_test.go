@@ -0,0 +1,95 @@
+package elseql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseUpdate(t *testing.T) {
+	tests := []struct {
+		statement string
+		format    string
+	}{
+		{
+			"UPDATE idx SET status = 'closed', n = n + 1 WHERE id = 10",
+			"UPDATE idx\nSET status = \"closed\", n = n + 1\nWHERE id = 10",
+		},
+		{
+			"update idx set total = (price + tax) * qty, a.b = null, n = n - (1 - m) limit 5",
+			"UPDATE idx\nSET total = (price + tax) * qty, a.b = null, n = n - (1 - m)\nLIMIT 5",
+		},
+		{
+			"DELETE FROM 'logs-2024' WHERE level = 'debug' LIMIT 100",
+			"DELETE FROM \"logs-2024\"\nWHERE level = \"debug\"\nLIMIT 100",
+		},
+		{
+			"DELETE FROM idx",
+			"DELETE FROM idx",
+		},
+		{
+			"UPDATE idx SET set = update + 1 WHERE delete = false",
+			"UPDATE idx\nSET set = update + 1\nWHERE delete = false",
+		},
+	}
+
+	for _, test := range tests {
+		parser := NewParser(test.statement)
+		if err := parser.Parse(); err != nil {
+			t.Fatalf("%v: %v", test.statement, err)
+		}
+
+		if f := Format(parser.Query()); f != test.format {
+			t.Errorf("%v: expected %q, got %q", test.statement, test.format, f)
+		}
+	}
+
+	for _, statement := range []string{
+		"UPDATE idx WHERE a = 1",
+		"UPDATE idx SET _id = 1",
+		"UPDATE idx SET a = ",
+		"DELETE idx WHERE a = 1",
+		"DELETE FROM idx LIMIT 10, 20",
+	} {
+		if err := NewParser(statement).Parse(); err == nil {
+			t.Errorf("%v: expected error", statement)
+		}
+	}
+}
+
+func TestSetScript(t *testing.T) {
+	parser := NewParser("UPDATE idx SET status = :status, n = n + 1, a.b = x * 2").BindNamed(map[string]interface{}{"status": "closed"})
+	if err := parser.Parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := jmap{
+		"source": "ctx._source['status'] = params.p0; ctx._source['n'] = (ctx._source['n'] + params.p1); ctx._source.putIfAbsent('a', [:]); ctx._source['a']['b'] = (ctx._source['x'] * params.p2);",
+		"lang":   "painless",
+		"params": jmap{"p0": "closed", "p1": 1, "p2": 2},
+	}
+
+	if script := setScript(parser.Query().SetList); !reflect.DeepEqual(script, expected) {
+		t.Errorf("unexpected script %v", script)
+	}
+
+	if s := sourceParents("a.b.c"); s != "ctx._source.putIfAbsent('a', [:]); ctx._source['a'].putIfAbsent('b', [:]); " {
+		t.Errorf("unexpected parents %q", s)
+	}
+
+	if err := NewParser("UPDATE idx SET a = ?").Parse(); err != nil {
+		t.Fatal(err)
+	} else if _, _, _, err := ParseQuery("UPDATE idx SET a = ?", ""); err == nil {
+		t.Error("expected error for ParseQuery(UPDATE)")
+	}
+}
+
+func TestByQueryResult(t *testing.T) {
+	res, err := byQueryResult(jmap{"total": 3.0, "updated": 2.0, "deleted": 0.0, "version_conflicts": 1.0, "noops": 0.0, "failures": jarr{}}, byQueryColumns, "", List)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rows := res["rows"].(jarr); !reflect.DeepEqual(rows, jarr{jarr{3.0, 2.0, 0.0, 1.0, 0.0, 0}}) || res["total"] != 3 {
+		t.Errorf("unexpected result %v", res)
+	}
+}